@@ -0,0 +1,163 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+
+	"ball-knowledge/config"
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+	"ball-knowledge/oidc"
+	"ball-knowledge/security"
+	"ball-knowledge/sessions"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrIdentityEmailUnverified is returned when the provider hasn't verified the identity's
+	// email, so it can't be trusted to match or create an account
+	ErrIdentityEmailUnverified = errors.New("the identity provider has not verified this email address")
+	// ErrAccountEmailUnverified is returned when the account with the identity's email hasn't
+	// verified it, so it may not belong to the same person
+	ErrAccountEmailUnverified = errors.New("an account with this email exists but hasn't verified it")
+	// ErrSignupDisabled is returned for identities without an account when OIDC_ALLOW_SIGNUP is off
+	ErrSignupDisabled = errors.New("no account uses this email address")
+)
+
+// IdentityLogin is the outcome of logging in with an OIDC identity
+type IdentityLogin struct {
+	User    models.User
+	Linked  bool // The identity was just linked to an existing account with its email
+	Created bool // A new account was created for the identity
+}
+
+var invalidUsernameChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// OIDCSignupAllowed reports whether OIDC logins without an account create one
+func OIDCSignupAllowed() bool {
+	return config.Bool("OIDC_ALLOW_SIGNUP", true)
+}
+
+// LoginWithIdentity returns the account an OIDC identity belongs to. Identities seen before
+// are found by provider and subject. New ones are linked to the account with the same
+// email, if both sides have verified it, or get a new account.
+func LoginWithIdentity(identity oidc.Identity) (IdentityLogin, error) {
+	var login IdentityLogin
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var link models.UserIdentity
+		err := tx.Where("issuer = ? AND subject = ?", identity.Issuer, identity.Subject).First(&link).Error
+		if err == nil {
+			if err := tx.Where("id = ?", link.UserID).First(&login.User).Error; err != nil {
+				return err
+			}
+			return tx.Model(&link).Updates(map[string]interface{}{"email": identity.Email, "last_login_at": time.Now()}).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if !identity.EmailVerified || identity.Email == "" {
+			return ErrIdentityEmailUnverified
+		}
+
+		err = tx.Where("LOWER(email) = LOWER(?)", identity.Email).First(&login.User).Error
+		switch {
+		case err == nil:
+			// Someone could have registered with this address without owning it; linking
+			// would let them log in to the real owner's account with their password
+			if !login.User.EmailVerified {
+				return ErrAccountEmailUnverified
+			}
+			login.Linked = true
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if !OIDCSignupAllowed() {
+				return ErrSignupDisabled
+			}
+			if login.User, err = createIdentityUser(tx, identity); err != nil {
+				return err
+			}
+			login.Created = true
+		default:
+			return err
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserID:      login.User.ID,
+			Issuer:      identity.Issuer,
+			Subject:     identity.Subject,
+			Email:       identity.Email,
+			LastLoginAt: time.Now(),
+		}).Error
+	})
+	return login, err
+}
+
+// createIdentityUser creates an account for an OIDC identity. Its email is verified by the
+// provider. It gets an unguessable password, which the user can replace with a password reset.
+func createIdentityUser(tx *gorm.DB, identity oidc.Identity) (models.User, error) {
+	username, err := uniqueUsername(tx, identity)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	password, _, err := sessions.NewToken()
+	if err != nil {
+		return models.User{}, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), security.PasswordCost)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user := models.User{
+		Username:      username,
+		Email:         identity.Email,
+		Password:      string(hashedPassword),
+		Role:          models.RoleUser,
+		EmailVerified: true,
+	}
+	if database.IsBootstrapAdmin(user.Email) {
+		user.Role = models.RoleAdmin
+	}
+
+	if err := tx.Create(&user).Error; err != nil {
+		return models.User{}, fmt.Errorf("failed to create user: %v", err)
+	}
+	return user, nil
+}
+
+// uniqueUsername derives a free username from the identity's preferred username or email,
+// following the registration rules: 3 to 20 letters, digits and underscores
+func uniqueUsername(tx *gorm.DB, identity oidc.Identity) (string, error) {
+	base := identity.PreferredUsername
+	if base == "" || strings.Contains(base, "@") {
+		base = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	base = strings.Trim(invalidUsernameChars.ReplaceAllString(base, "_"), "_")
+	if len(base) > 15 {
+		base = base[:15]
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+
+	candidate := base
+	for attempt := 0; attempt < 10; attempt++ {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("LOWER(username) = LOWER(?)", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s_%d", base, rand.Intn(10000))
+	}
+	return "", errors.New("failed to find a free username")
+}
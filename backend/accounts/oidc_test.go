@@ -0,0 +1,237 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ball-knowledge/database"
+	"ball-knowledge/database/databasetest"
+	"ball-knowledge/models"
+	"ball-knowledge/oidc"
+	"ball-knowledge/oidc/fakeidp"
+	"ball-knowledge/sessions"
+)
+
+var alice = fakeidp.User{
+	Subject:       "alice-subject",
+	Email:         "alice@example.com",
+	EmailVerified: true,
+	Name:          "Alice",
+	Username:      "alice",
+}
+
+func TestOIDCLoginCreatesAndFindsAccount(t *testing.T) {
+	databasetest.Open(t)
+	startIdentityProvider(t, alice)
+
+	login := loginWithProvider(t)
+	if !login.Created || login.Linked {
+		t.Fatalf("first login: created %v, linked %v; want a new account", login.Created, login.Linked)
+	}
+	if login.User.Username != "alice" || !login.User.EmailVerified {
+		t.Errorf("new account is %q, verified %v", login.User.Username, login.User.EmailVerified)
+	}
+
+	again := loginWithProvider(t)
+	if again.Created || again.Linked || again.User.ID != login.User.ID {
+		t.Errorf("second login: created %v, linked %v, user %s; want the same account", again.Created, again.Linked, again.User.ID)
+	}
+}
+
+func TestOIDCLoginLinksVerifiedAccount(t *testing.T) {
+	databasetest.Open(t)
+	startIdentityProvider(t, alice)
+
+	existing := createUser(t, "Alice@Example.com")
+	database.DB.Model(&existing).Update("email_verified", true)
+
+	login := loginWithProvider(t)
+	if !login.Linked || login.Created || login.User.ID != existing.ID {
+		t.Fatalf("login: created %v, linked %v, user %s; want a link to %s", login.Created, login.Linked, login.User.ID, existing.ID)
+	}
+
+	var links int64
+	database.DB.Model(&models.UserIdentity{}).Where("user_id = ? AND subject = ?", existing.ID, alice.Subject).Count(&links)
+	if links != 1 {
+		t.Errorf("account has %d links to the identity, want 1", links)
+	}
+}
+
+func TestOIDCLoginDoesNotLinkUnverifiedAccount(t *testing.T) {
+	databasetest.Open(t)
+	startIdentityProvider(t, alice)
+	createUser(t, alice.Email)
+
+	identity := signIn(t)
+	if _, err := LoginWithIdentity(identity); !errors.Is(err, ErrAccountEmailUnverified) {
+		t.Errorf("got %v, want ErrAccountEmailUnverified", err)
+	}
+}
+
+func TestOIDCLoginRejectsUnverifiedIdentityEmail(t *testing.T) {
+	databasetest.Open(t)
+	unverified := alice
+	unverified.EmailVerified = false
+	startIdentityProvider(t, unverified)
+
+	identity := signIn(t)
+	if _, err := LoginWithIdentity(identity); !errors.Is(err, ErrIdentityEmailUnverified) {
+		t.Errorf("got %v, want ErrIdentityEmailUnverified", err)
+	}
+
+	var users int64
+	database.DB.Model(&models.User{}).Count(&users)
+	if users != 0 {
+		t.Errorf("%d accounts created for an unverified email", users)
+	}
+}
+
+func TestOIDCLoginRejectsReusedState(t *testing.T) {
+	databasetest.Open(t)
+	startIdentityProvider(t, alice)
+
+	state, code := startLogin(t)
+	if _, err := oidc.Complete(context.Background(), state, code); err != nil {
+		t.Fatalf("first callback failed: %v", err)
+	}
+	if _, err := oidc.Complete(context.Background(), state, code); !errors.Is(err, oidc.ErrInvalidState) {
+		t.Errorf("replayed callback: got %v, want ErrInvalidState", err)
+	}
+}
+
+func TestOIDCLoginRejectsExpiredState(t *testing.T) {
+	databasetest.Open(t)
+	startIdentityProvider(t, alice)
+
+	state, code := startLogin(t)
+	editLoginState(t, state, "expires_at", time.Now().Add(-time.Second))
+
+	if _, err := oidc.Complete(context.Background(), state, code); !errors.Is(err, oidc.ErrInvalidState) {
+		t.Errorf("expired state: got %v, want ErrInvalidState", err)
+	}
+}
+
+func TestOIDCLoginRejectsWrongCodeVerifier(t *testing.T) {
+	databasetest.Open(t)
+	startIdentityProvider(t, alice)
+
+	// As if an attacker who intercepted the code redeemed it without the verifier
+	state, code := startLogin(t)
+	editLoginState(t, state, "code_verifier", strings.Repeat("x", 43))
+
+	_, err := oidc.Complete(context.Background(), state, code)
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("wrong PKCE verifier: got %v, want invalid_grant", err)
+	}
+}
+
+func TestOIDCLoginRejectsNonceMismatch(t *testing.T) {
+	databasetest.Open(t)
+	startIdentityProvider(t, alice)
+
+	// As if the ID token was issued for another login
+	state, code := startLogin(t)
+	editLoginState(t, state, "nonce", "another-login")
+
+	_, err := oidc.Complete(context.Background(), state, code)
+	if err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Errorf("nonce mismatch: got %v, want a nonce error", err)
+	}
+}
+
+// startIdentityProvider configures OIDC login against a fake provider that signs in user
+func startIdentityProvider(t *testing.T, user fakeidp.User) {
+	t.Helper()
+
+	var provider *fakeidp.Provider
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provider.Handler().ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	var err error
+	provider, err = fakeidp.New(fakeidp.Options{
+		Issuer:       server.URL,
+		ClientID:     "ball-knowledge",
+		ClientSecret: "secret",
+		User:         user,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("OIDC_ISSUER", server.URL)
+	t.Setenv("OIDC_CLIENT_ID", "ball-knowledge")
+	t.Setenv("OIDC_CLIENT_SECRET", "secret")
+	t.Setenv("OIDC_REDIRECT_URL", "http://localhost/api/auth/oidc/callback")
+	t.Setenv("OIDC_ALLOW_SIGNUP", "true")
+}
+
+// startLogin begins a login and follows the browser to the provider, returning the
+// state and authorization code it redirects back with
+func startLogin(t *testing.T) (state, code string) {
+	t.Helper()
+
+	authURL, state, err := oidc.Begin(context.Background(), "")
+	if err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := browser.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("provider answered %d, location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if got := callback.Query().Get("state"); got != state {
+		t.Fatalf("provider returned state %q, want %q", got, state)
+	}
+	if callback.Query().Get("code") == "" {
+		t.Fatalf("provider returned no code: %s", callback)
+	}
+	return state, callback.Query().Get("code")
+}
+
+// signIn goes through a whole login and returns the identity the provider vouched for
+func signIn(t *testing.T) oidc.Identity {
+	t.Helper()
+	state, code := startLogin(t)
+	identity, err := oidc.Complete(context.Background(), state, code)
+	if err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+	return identity
+}
+
+func loginWithProvider(t *testing.T) IdentityLogin {
+	t.Helper()
+	login, err := LoginWithIdentity(signIn(t))
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	return login
+}
+
+// editLoginState changes what the server stored for a login in progress
+func editLoginState(t *testing.T, state, column string, value interface{}) {
+	t.Helper()
+	result := database.DB.Model(&models.OIDCLoginState{}).
+		Where("state_hash = ?", sessions.HashToken(state)).
+		Update(column, value)
+	if result.Error != nil || result.RowsAffected != 1 {
+		t.Fatalf("failed to edit login state: %v", result.Error)
+	}
+}
@@ -0,0 +1,177 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"ball-knowledge/config"
+	"ball-knowledge/database"
+	"ball-knowledge/mail"
+	"ball-knowledge/models"
+	"ball-knowledge/security"
+	"ball-knowledge/sessions"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidResetToken is returned for unknown, used or expired password reset tokens
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// PasswordResetTTL is how long a password reset link stays valid
+func PasswordResetTTL() time.Duration {
+	return config.Duration("PASSWORD_RESET_TTL", time.Hour)
+}
+
+// PasswordResetInterval is the minimum time between password reset emails to one account
+func PasswordResetInterval() time.Duration {
+	return config.Duration("PASSWORD_RESET_INTERVAL", 5*time.Minute)
+}
+
+// RequestPasswordReset emails a password reset link to the account with this email.
+// Unknown addresses are ignored without an error, so callers can't reveal which
+// addresses have accounts. Earlier reset links of the account stop working.
+// Requests within PasswordResetInterval of the last link are ignored the same way,
+// so nobody can flood an inbox or keep cancelling the owner's link.
+func RequestPasswordReset(email string) error {
+	var user models.User
+	err := database.DB.Where("LOWER(email) = LOWER(?)", strings.TrimSpace(email)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("🔐 Password reset requested for unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var recent int64
+	if err := database.DB.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-PasswordResetInterval())).
+		Count(&recent).Error; err != nil {
+		return err
+	}
+	if recent > 0 {
+		log.Printf("🔐 Password reset for user %s requested again too soon, ignoring", user.ID)
+		return nil
+	}
+
+	token, hash, err := sessions.NewToken()
+	if err != nil {
+		return err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := expireResetTokens(tx, user.ID); err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: hash,
+			ExpiresAt: time.Now().Add(PasswordResetTTL()),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store password reset token: %v", err)
+	}
+
+	mail.SendAsync(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Ball Knowledge password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked to reset the password of your Ball Knowledge account. "+
+			"If it was you, choose a new password here within %s:\n\n%s\n\n"+
+			"If it wasn't, you can ignore this email; your password stays the same.\n",
+			user.Username, formatDuration(PasswordResetTTL()), resetLink(token)),
+	})
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token and returns the user whose password
+// changed. The token can only be used once, and every existing login of the user is ended.
+// Access tokens already issued stay valid until they expire.
+func ResetPassword(token, password string) (models.User, error) {
+	var user models.User
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), security.PasswordCost)
+	if err != nil {
+		return user, fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var reset models.PasswordResetToken
+		if err := tx.Where("token_hash = ?", sessions.HashToken(token)).First(&reset).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+		if reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+			return ErrInvalidResetToken
+		}
+
+		// Claim the token, so a concurrent reset with the same token fails
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+
+		if err := tx.Where("id = ?", reset.UserID).First(&user).Error; err != nil {
+			return ErrInvalidResetToken
+		}
+		if err := tx.Model(&user).Update("password", string(hashedPassword)).Error; err != nil {
+			return err
+		}
+		if err := expireResetTokens(tx, user.ID); err != nil {
+			return err
+		}
+		return sessions.RevokeAll(tx, user.ID)
+	})
+	if err != nil {
+		return user, err
+	}
+
+	log.Printf("🔐 Password reset for user %s, all sessions revoked", user.ID)
+	mail.SendAsync(mail.Message{
+		To:      user.Email,
+		Subject: "Your Ball Knowledge password was changed",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"The password of your Ball Knowledge account was just reset and you've been "+
+			"logged out everywhere. If you didn't do this, reset your password again right away.\n",
+			user.Username),
+	})
+	return user, nil
+}
+
+// expireResetTokens marks a user's outstanding reset tokens as used
+func expireResetTokens(tx *gorm.DB, userID uuid.UUID) error {
+	return tx.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+// formatDuration renders a duration compactly, e.g. "45s", "1h" or "1h30m"
+func formatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	s := d.Round(time.Minute).String()
+	s = strings.TrimSuffix(s, "0s")
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// resetLink is the frontend page that completes a reset (<APP_BASE_URL>/reset-password?token=<token>)
+func resetLink(token string) string {
+	base := strings.TrimRight(config.String("APP_BASE_URL", "http://localhost:3000"), "/")
+	return base + "/reset-password?token=" + token
+}
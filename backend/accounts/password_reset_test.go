@@ -0,0 +1,250 @@
+package accounts
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"ball-knowledge/database"
+	"ball-knowledge/database/databasetest"
+	"ball-knowledge/mail"
+	"ball-knowledge/models"
+	"ball-knowledge/sessions"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var resetTokenPattern = regexp.MustCompile(`reset-password\?token=([A-Za-z0-9_-]+)`)
+
+func TestPasswordResetTokenWorksOnce(t *testing.T) {
+	databasetest.Open(t)
+	outbox := startSMTPServer(t)
+	user := createUser(t, "reset@example.com")
+
+	refreshToken, err := sessions.Issue(user.ID, sessions.Client{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RequestPasswordReset("Reset@Example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := resetToken(t, outbox)
+
+	if _, err := ResetPassword(token, "new password"); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if notice := receive(t, outbox); !strings.Contains(notice, "password was changed") {
+		t.Errorf("expected a password changed notice, got:\n%s", notice)
+	}
+
+	database.DB.First(&user, "id = ?", user.ID)
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("new password")) != nil {
+		t.Error("password wasn't changed")
+	}
+	if _, _, err := sessions.Rotate(refreshToken, sessions.Client{}); !errors.Is(err, sessions.ErrInvalidToken) {
+		t.Errorf("refresh token issued before the reset still works: %v", err)
+	}
+
+	if _, err := ResetPassword(token, "another password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("second reset with the same token: got %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestPasswordResetRejectsExpiredTokens(t *testing.T) {
+	databasetest.Open(t)
+	outbox := startSMTPServer(t)
+	user := createUser(t, "expired@example.com")
+
+	if err := RequestPasswordReset(user.Email); err != nil {
+		t.Fatal(err)
+	}
+	token := resetToken(t, outbox)
+
+	database.DB.Model(&models.PasswordResetToken{}).
+		Where("user_id = ?", user.ID).
+		Update("expires_at", time.Now().Add(-time.Second))
+
+	if _, err := ResetPassword(token, "new password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("reset with an expired token: got %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestPasswordResetInvalidatesEarlierLinks(t *testing.T) {
+	databasetest.Open(t)
+	outbox := startSMTPServer(t)
+	user := createUser(t, "twice@example.com")
+
+	if err := RequestPasswordReset(user.Email); err != nil {
+		t.Fatal(err)
+	}
+	first := resetToken(t, outbox)
+
+	// Another link can be requested once the interval has passed
+	database.DB.Model(&models.PasswordResetToken{}).
+		Where("user_id = ?", user.ID).
+		Update("created_at", time.Now().Add(-PasswordResetInterval()))
+
+	if err := RequestPasswordReset(user.Email); err != nil {
+		t.Fatal(err)
+	}
+	second := resetToken(t, outbox)
+
+	if _, err := ResetPassword(first, "new password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("reset with the earlier token: got %v, want ErrInvalidResetToken", err)
+	}
+	if _, err := ResetPassword(second, "new password"); err != nil {
+		t.Fatalf("reset with the latest token failed: %v", err)
+	}
+	receive(t, outbox) // Password changed notice
+}
+
+func TestPasswordResetIgnoresRepeatedRequests(t *testing.T) {
+	databasetest.Open(t)
+	outbox := startSMTPServer(t)
+	user := createUser(t, "impatient@example.com")
+
+	if err := RequestPasswordReset(user.Email); err != nil {
+		t.Fatal(err)
+	}
+	token := resetToken(t, outbox)
+
+	if err := RequestPasswordReset(user.Email); err != nil {
+		t.Fatalf("repeated request: got %v, want no error", err)
+	}
+	expectNoMail(t, outbox)
+
+	if _, err := ResetPassword(token, "new password"); err != nil {
+		t.Fatalf("first link stopped working after a throttled request: %v", err)
+	}
+	receive(t, outbox) // Password changed notice
+}
+
+func TestPasswordResetIgnoresUnknownEmails(t *testing.T) {
+	databasetest.Open(t)
+	outbox := startSMTPServer(t)
+
+	if err := RequestPasswordReset("nobody@example.com"); err != nil {
+		t.Fatalf("unknown email: got %v, want no error", err)
+	}
+	expectNoMail(t, outbox)
+}
+
+func createUser(t *testing.T, email string) models.User {
+	t.Helper()
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("old password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{
+		Username: strings.SplitN(email, "@", 2)[0],
+		Email:    email,
+		Password: string(hashedPassword),
+		Role:     models.RoleUser,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// resetToken waits for a password reset email and returns the token in its link
+func resetToken(t *testing.T, outbox <-chan string) string {
+	t.Helper()
+	msg := receive(t, outbox)
+	match := resetTokenPattern.FindStringSubmatch(msg)
+	if match == nil {
+		t.Fatalf("no reset link in email:\n%s", msg)
+	}
+	return match[1]
+}
+
+func receive(t *testing.T, outbox <-chan string) string {
+	t.Helper()
+	select {
+	case msg := <-outbox:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email was sent")
+		return ""
+	}
+}
+
+func expectNoMail(t *testing.T, outbox <-chan string) {
+	t.Helper()
+	select {
+	case msg := <-outbox:
+		t.Errorf("unexpected email:\n%s", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// startSMTPServer sends mail through the SMTP mailer to a stub server for the rest of the
+// test, and returns the messages it receives
+func startSMTPServer(t *testing.T) <-chan string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, outbox)
+		}
+	}()
+
+	mail.SetMailer(&mail.SMTPMailer{
+		Host: "127.0.0.1",
+		Port: listener.Addr().(*net.TCPAddr).Port,
+		From: "Ball Knowledge <no-reply@localhost>",
+	})
+	t.Cleanup(func() {
+		mail.SetMailer(&mail.LogMailer{})
+		listener.Close()
+	})
+	return outbox
+}
+
+// serveSMTP speaks just enough SMTP (RFC 5321) for net/smtp to deliver one message
+func serveSMTP(conn net.Conn, outbox chan<- string) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost stub")
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+		case "EHLO", "HELO":
+			text.PrintfLine("250 localhost")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			body, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			outbox <- string(body)
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
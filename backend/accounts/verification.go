@@ -0,0 +1,128 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ball-knowledge/auth"
+	"ball-knowledge/config"
+	"ball-knowledge/database"
+	"ball-knowledge/mail"
+	"ball-knowledge/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidVerificationToken is returned for bad or expired verification links, or links
+	// for an address the account no longer uses
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification link")
+	// ErrAlreadyVerified is returned when resending to an account that is already verified
+	ErrAlreadyVerified = errors.New("email address is already verified")
+)
+
+// ResendThrottledError is returned when a verification email was sent too recently
+type ResendThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ResendThrottledError) Error() string {
+	return fmt.Sprintf("verification email sent recently, try again in %s", formatDuration(e.RetryAfter))
+}
+
+// VerificationTTL is how long an email verification link stays valid
+func VerificationTTL() time.Duration {
+	return config.Duration("EMAIL_VERIFICATION_TTL", 48*time.Hour)
+}
+
+// ResendInterval is the minimum time between verification emails to one account
+func ResendInterval() time.Duration {
+	return config.Duration("EMAIL_VERIFICATION_RESEND_INTERVAL", 5*time.Minute)
+}
+
+// VerifiedEmailRequiredToPredict reports whether unverified users are blocked from predicting
+func VerifiedEmailRequiredToPredict() bool {
+	return config.Bool("EMAIL_VERIFICATION_REQUIRED_TO_PREDICT", false)
+}
+
+// SendVerification emails a signed verification link to a user
+func SendVerification(user models.User) error {
+	token, err := auth.IssueEmailVerificationToken(user.ID.String(), user.Email, VerificationTTL())
+	if err != nil {
+		return fmt.Errorf("failed to sign verification link: %v", err)
+	}
+
+	if err := database.DB.Model(&models.User{}).
+		Where("id = ?", user.ID).
+		Update("verification_sent_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	mail.SendAsync(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your Ball Knowledge email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Welcome to Ball Knowledge! Confirm this is your email address within %s:\n\n%s\n\n"+
+			"If you didn't sign up, you can ignore this email.\n",
+			user.Username, formatDuration(VerificationTTL()), verificationLink(token)),
+	})
+	return nil
+}
+
+// ResendVerification sends a new verification link, at most once per ResendInterval.
+// It returns a *ResendThrottledError when called too soon.
+func ResendVerification(user models.User) error {
+	if user.EmailVerified {
+		return ErrAlreadyVerified
+	}
+	if user.VerificationSentAt != nil {
+		if wait := time.Until(user.VerificationSentAt.Add(ResendInterval())); wait > 0 {
+			return &ResendThrottledError{RetryAfter: wait}
+		}
+	}
+	return SendVerification(user)
+}
+
+// VerifyEmail marks the account of a verification link as verified. Verifying twice is not an error.
+func VerifyEmail(token string) (models.User, error) {
+	var user models.User
+
+	userID, email, err := auth.ParseEmailVerificationToken(token)
+	if err != nil {
+		return user, ErrInvalidVerificationToken
+	}
+
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, ErrInvalidVerificationToken
+		}
+		return user, err
+	}
+	if !strings.EqualFold(user.Email, email) {
+		return user, ErrInvalidVerificationToken
+	}
+
+	if !user.EmailVerified {
+		if err := database.DB.Model(&user).Update("email_verified", true).Error; err != nil {
+			return user, err
+		}
+		// The bootstrap admin is only promoted once they've proven they own the address
+		if database.IsBootstrapAdmin(user.Email) {
+			if err := database.PromoteBootstrapAdmin(); err != nil {
+				return user, err
+			}
+			if err := database.DB.Where("id = ?", user.ID).First(&user).Error; err != nil {
+				return user, err
+			}
+		}
+	}
+	return user, nil
+}
+
+// verificationLink is the frontend page that completes verification (<APP_BASE_URL>/verify-email?token=<token>)
+func verificationLink(token string) string {
+	base := strings.TrimRight(config.String("APP_BASE_URL", "http://localhost:3000"), "/")
+	return base + "/verify-email?token=" + token
+}
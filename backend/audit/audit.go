@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"ball-knowledge/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Audited actions
+const (
+	ActionMatchCreate     = "match.create"
+	ActionMatchUpdate     = "match.update"
+	ActionMatchResult     = "match.result"
+	ActionMatchStatus     = "match.status"
+	ActionMatchReschedule = "match.reschedule"
+	ActionMatchUnlock     = "match.unlock"
+	ActionMatchDelete     = "match.delete"
+	ActionUserRole        = "user.role"
+	ActionRescore         = "leaderboard.rescore"
+	ActionRebuild         = "leaderboard.rebuild"
+	ActionRuleSetCreate   = "rule_set.create"
+	ActionRuleSetAssign   = "rule_set.assign"
+)
+
+// Entity types
+const (
+	EntityMatch       = "match"
+	EntityUser        = "user"
+	EntityLeaderboard = "leaderboard"
+	EntityRuleSet     = "rule_set"
+	EntityCompetition = "competition_scoring"
+)
+
+// Record writes an audit log entry using tx, so it commits or rolls back with the
+// action itself. actorID is nil for actions taken by the system. details is stored as JSON.
+func Record(tx *gorm.DB, actorID *uuid.UUID, action, entityType, entityID string, details interface{}) error {
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %v", err)
+	}
+
+	entry := models.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Details:    encoded,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to write audit log: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ball-knowledge/config"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	// ErrInvalidSignature is returned for tokens not signed by an active key
+	ErrInvalidSignature = errors.New("invalid token signature")
+	// ErrInvalidToken is returned for malformed or expired tokens, or tokens
+	// for another issuer or audience
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Token types, set in the typ header. Access tokens use the JWT access token type
+// (RFC 9068); verifiers must check it, since single-purpose tokens are signed with the
+// same keys. Tokens signed before types were introduced say "JWT" and are told apart
+// by their claims until they expire.
+const (
+	accessTokenType = "at+jwt"
+	legacyTokenType = "JWT"
+)
+
+// Claims are the claims carried by an access token
+type Claims struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+	MFA    bool   `json:"mfa,omitempty"` // The login passed two-factor authentication
+	jwt.RegisteredClaims
+}
+
+var (
+	loadOnce sync.Once
+	active   *keySet
+	loadErr  error
+)
+
+// Init loads the signing keys from JWT_PRIVATE_KEYS, JWT_PUBLIC_KEYS, JWT_KEYS and JWT_SECRET.
+// Without any, a random secret is generated for development; in release mode that is an
+// error instead, since tokens would stop validating on every restart.
+func Init() error {
+	loadOnce.Do(func() {
+		active, loadErr = loadKeys()
+	})
+	return loadErr
+}
+
+// AccessTokenTTL is how long access tokens are valid
+func AccessTokenTTL() time.Duration {
+	return config.Duration("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// Issuer is the iss claim of access tokens; empty leaves it out
+func Issuer() string {
+	return config.String("JWT_ISSUER", "")
+}
+
+// Audience is the aud claim of access tokens; empty leaves it out
+func Audience() []string {
+	return config.List("JWT_AUDIENCE", nil)
+}
+
+// RequiresTwoFactor reports whether REQUIRE_2FA_ROLES makes users with this role
+// log in with two-factor authentication before using role-restricted routes
+func RequiresTwoFactor(role string) bool {
+	for _, required := range config.List("REQUIRE_2FA_ROLES", nil) {
+		if strings.EqualFold(required, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// IssueAccessToken signs an access token for a user with the current signing key.
+// mfa records that the login passed two-factor authentication.
+func IssueAccessToken(userID, role string, mfa bool) (string, error) {
+	if err := Init(); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		UserID: userID,
+		Role:   role,
+		MFA:    mfa,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    Issuer(),
+			Audience:  Audience(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	return sign(claims, accessTokenType)
+}
+
+// ParseAccessToken validates an access token and returns its claims. When an issuer or
+// audience is configured, the token must carry it.
+func ParseAccessToken(tokenString string) (*Claims, error) {
+	if err := Init(); err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	if err := parse(tokenString, claims, accessTokenType); err != nil {
+		return nil, err
+	}
+	// Tokens for other purposes, such as email verification, carry no user_id
+	if claims.UserID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	if issuer := Issuer(); issuer != "" && !claims.VerifyIssuer(issuer, true) {
+		return nil, ErrInvalidToken
+	}
+	if audience := Audience(); len(audience) > 0 && !hasAudience(claims, audience) {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// sign signs claims as a token of type typ with the current signing key
+func sign(claims jwt.Claims, typ string) (string, error) {
+	signing := active.keys[active.signingKeyID]
+	token := jwt.NewWithClaims(signing.method, claims)
+	token.Header["typ"] = typ
+	token.Header["kid"] = active.signingKeyID
+	return token.SignedString(signing.signKey)
+}
+
+// parse verifies a token's type, signature and expiry against the active keys and decodes its claims
+func parse(tokenString string, claims jwt.Claims, typ string) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = legacyKeyID
+		}
+		key, ok := active.keys[kid]
+		if !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+
+		// The key decides the algorithm, so a token can't pick a weaker one
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %v for key %q", token.Header["alg"], kid)
+		}
+		return key.verifyKey, nil
+	})
+	if err != nil {
+		if errors.Is(err, jwt.ErrSignatureInvalid) {
+			return ErrInvalidSignature
+		}
+		return ErrInvalidToken
+	}
+	if !token.Valid {
+		return ErrInvalidToken
+	}
+	if tokenType, _ := token.Header["typ"].(string); tokenType != typ && tokenType != legacyTokenType {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// hasAudience reports whether the token is meant for any of the configured audiences
+func hasAudience(claims *Claims, audience []string) bool {
+	for _, aud := range audience {
+		if claims.VerifyAudience(aud, true) {
+			return true
+		}
+	}
+	return false
+}
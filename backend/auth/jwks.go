@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+)
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // Ed25519
+	X         string `json:"x,omitempty"`   // Ed25519 public key
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys that verify access tokens, so other services can check
+// tokens without sharing a secret. HS256 keys are never published. The same keys sign
+// email verification links and two-factor challenges, so services must only accept
+// tokens with the typ header "at+jwt", or check the JWT_AUDIENCE audience.
+func JWKS() (JWKSet, error) {
+	set := JWKSet{Keys: []JWK{}}
+	if err := Init(); err != nil {
+		return set, err
+	}
+
+	for kid, k := range active.keys {
+		if !k.public() {
+			continue
+		}
+
+		jwk := JWK{KeyID: kid, Use: "sig", Algorithm: k.method.Alg()}
+		switch pub := k.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = encode(pub.N.Bytes())
+			jwk.E = encode(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = encode(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	// The signing key first, then by ID, so the document is stable
+	sort.Slice(set.Keys, func(i, j int) bool {
+		a, b := set.Keys[i].KeyID, set.Keys[j].KeyID
+		if a == active.signingKeyID || b == active.signingKeyID {
+			return a == active.signingKeyID
+		}
+		return a < b
+	})
+	return set, nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"ball-knowledge/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// legacyKeyID is the key ID of JWT_SECRET, which also validates tokens issued without a kid header
+const legacyKeyID = "default"
+
+// key is a token signing key. signKey is nil for verify-only keys, such as retired
+// asymmetric keys whose private half has been removed.
+type key struct {
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// public reports whether the key can be published, i.e. it's asymmetric
+func (k key) public() bool {
+	return k.method.Alg() != jwt.SigningMethodHS256.Alg()
+}
+
+// keySet holds the active keys by ID. New tokens are signed with signingKeyID; every key
+// in the set validates, so keys can be rotated without logging users out.
+type keySet struct {
+	keys         map[string]key
+	signingKeyID string
+}
+
+// loadKeys reads the configured keys, each list a comma-separated set of kid:value pairs:
+//   - JWT_PRIVATE_KEYS: kid:path of an RSA (RS256) or Ed25519 (EdDSA) private key PEM file
+//   - JWT_PUBLIC_KEYS: kid:path of a public key PEM file, to validate tokens of retired keys
+//   - JWT_KEYS: kid:secret HS256 keys, with JWT_SECRET added as key "default"
+//
+// The first private key signs new tokens, or the first HS256 key when there are no private
+// keys; JWT_SIGNING_KEY_ID picks another.
+func loadKeys() (*keySet, error) {
+	set := &keySet{keys: map[string]key{}}
+
+	for _, entry := range config.List("JWT_PRIVATE_KEYS", nil) {
+		kid, path, err := splitKeyEntry("JWT_PRIVATE_KEYS", entry)
+		if err != nil {
+			return nil, err
+		}
+		k, err := loadPrivateKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("JWT key %q: %v", kid, err)
+		}
+		if err := set.add(kid, k); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range config.List("JWT_PUBLIC_KEYS", nil) {
+		kid, path, err := splitKeyEntry("JWT_PUBLIC_KEYS", entry)
+		if err != nil {
+			return nil, err
+		}
+		k, err := loadPublicKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("JWT key %q: %v", kid, err)
+		}
+		if err := set.add(kid, k); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range config.List("JWT_KEYS", nil) {
+		kid, secret, err := splitKeyEntry("JWT_KEYS", entry)
+		if err != nil {
+			return nil, err
+		}
+		if err := set.addSecret(kid, secret); err != nil {
+			return nil, err
+		}
+	}
+
+	if secret := config.String("JWT_SECRET", ""); secret != "" {
+		if err := set.addSecret(legacyKeyID, secret); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(set.keys) == 0 {
+		if gin.Mode() == gin.ReleaseMode {
+			return nil, errors.New("JWT_PRIVATE_KEYS, JWT_SECRET or JWT_KEYS must be set in release mode")
+		}
+
+		bytes := make([]byte, 32)
+		if _, err := rand.Read(bytes); err != nil {
+			return nil, fmt.Errorf("failed to generate JWT secret: %v", err)
+		}
+		log.Println("⚠️  WARNING: Using random JWT secret. Set JWT_SECRET environment variable for production!")
+		if err := set.addSecret(legacyKeyID, hex.EncodeToString(bytes)); err != nil {
+			return nil, err
+		}
+	}
+
+	if kid := config.String("JWT_SIGNING_KEY_ID", ""); kid != "" {
+		k, ok := set.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("JWT_SIGNING_KEY_ID %q is not one of the configured keys", kid)
+		}
+		if k.signKey == nil {
+			return nil, fmt.Errorf("JWT_SIGNING_KEY_ID %q is a public key and cannot sign", kid)
+		}
+		set.signingKeyID = kid
+	}
+	if set.signingKeyID == "" {
+		return nil, errors.New("no JWT key can sign tokens; JWT_PUBLIC_KEYS only validate them")
+	}
+
+	log.Printf("🔑 JWT signing key %q (%s), %d active key(s)",
+		set.signingKeyID, set.keys[set.signingKeyID].method.Alg(), len(set.keys))
+	return set, nil
+}
+
+func (set *keySet) add(kid string, k key) error {
+	if _, exists := set.keys[kid]; exists {
+		return fmt.Errorf("duplicate JWT key ID %q", kid)
+	}
+	set.keys[kid] = k
+
+	// Asymmetric keys are preferred for signing, since anyone can verify them
+	if k.signKey == nil {
+		return nil
+	}
+	if set.signingKeyID == "" || (k.public() && !set.keys[set.signingKeyID].public()) {
+		set.signingKeyID = kid
+	}
+	return nil
+}
+
+func (set *keySet) addSecret(kid, secret string) error {
+	if len(secret) < 32 {
+		log.Printf("⚠️  Warning: JWT key %q is shorter than 32 characters", kid)
+	}
+	return set.add(kid, key{
+		method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	})
+}
+
+func splitKeyEntry(setting, entry string) (string, string, error) {
+	kid, value, ok := strings.Cut(entry, ":")
+	kid, value = strings.TrimSpace(kid), strings.TrimSpace(value)
+	if !ok || kid == "" || value == "" {
+		return "", "", fmt.Errorf("%s entries must be kid:value, got %q", setting, entry)
+	}
+	return kid, value, nil
+}
+
+// loadPrivateKey reads an RSA or Ed25519 private key from a PKCS#8 or PKCS#1 PEM file
+func loadPrivateKey(path string) (key, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return key{}, err
+	}
+
+	var parsed interface{}
+	if parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return key{}, fmt.Errorf("%s is not a PKCS#8 or PKCS#1 private key", path)
+		}
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return key{}, fmt.Errorf("%s holds an unsupported key type %T", path, parsed)
+	}
+	k, err := publicKey(signer.Public())
+	if err != nil {
+		return key{}, fmt.Errorf("%s: %v", path, err)
+	}
+	k.signKey = parsed
+	return k, nil
+}
+
+// loadPublicKey reads an RSA or Ed25519 public key from a PKIX PEM file
+func loadPublicKey(path string) (key, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return key{}, err
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return key{}, fmt.Errorf("%s is not a PKIX public key", path)
+	}
+	k, err := publicKey(parsed)
+	if err != nil {
+		return key{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return k, nil
+}
+
+// publicKey returns a verify-only key for an RSA or Ed25519 public key
+func publicKey(pub crypto.PublicKey) (key, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return key{}, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return key{method: jwt.SigningMethodRS256, verifyKey: pub}, nil
+	case ed25519.PublicKey:
+		return key{method: jwt.SigningMethodEdDSA, verifyKey: pub}, nil
+	}
+	return key{}, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", pub)
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+	return block, nil
+}
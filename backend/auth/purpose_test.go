@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestPurposeTokensAreNotAccessTokens(t *testing.T) {
+	challenge, err := IssueTwoFactorChallenge("user-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verification, err := IssueEmailVerificationToken("user-1", "user@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{"challenge": challenge, "verification": verification} {
+		if _, err := ParseAccessToken(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s accepted as an access token: %v", name, err)
+		}
+
+		// What an external verifier checking the typ header or audience sees
+		parsed, _, err := new(jwt.Parser).ParseUnverified(token, &purposeClaims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ := parsed.Header["typ"]; typ == accessTokenType {
+			t.Errorf("%s is typed %v", name, typ)
+		}
+		if claims := parsed.Claims.(*purposeClaims); len(claims.Audience) != 1 || claims.Audience[0] == "" {
+			t.Errorf("%s has audience %v, want its own", name, claims.Audience)
+		}
+	}
+}
+
+func TestAccessTokensRequireAccessType(t *testing.T) {
+	access, err := IssueAccessToken("user-1", "user", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseAccessToken(access); err != nil {
+		t.Fatalf("access token rejected: %v", err)
+	}
+	if _, err := ParseTwoFactorChallenge(access); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("access token accepted as a two-factor challenge: %v", err)
+	}
+
+	// Claims that would pass as an access token, typed for another purpose
+	retyped, err := sign(&Claims{
+		UserID:           "user-1",
+		Role:             "admin",
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+	}, purposeTwoFactorChallenge+"+jwt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseAccessToken(retyped); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token typed %s+jwt accepted as an access token: %v", purposeTwoFactorChallenge, err)
+	}
+}
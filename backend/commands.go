@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"ball-knowledge/controllers"
+	"ball-knowledge/database"
+	"ball-knowledge/leaderboard"
+	"ball-knowledge/models"
+	"ball-knowledge/oidc/fakeidp"
+	"ball-knowledge/security"
+	"ball-knowledge/settlement"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// command is a maintenance task that can be run from the command line
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []command{
+	{"rescore", "Re-score predictions for a season or gameweek", rescoreCommand},
+	{"rebuild-standings", "Recompute leaderboard standings from predictions", rebuildStandingsCommand},
+	{"create-admin", "Create an admin account, or make an existing user an admin", createAdminCommand},
+}
+
+// standaloneCommands don't use the database
+var standaloneCommands = []command{
+	{"fake-idp", "Run a fake OpenID Connect provider for trying OIDC login locally", fakeIDPCommand},
+}
+
+// runCommand runs the named command and returns the process exit code
+func runCommand(args []string) int {
+	for _, cmd := range standaloneCommands {
+		if cmd.name != args[0] {
+			continue
+		}
+		if err := cmd.run(args[1:]); err != nil {
+			log.Printf("❌ %s failed: %v", cmd.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		if err := database.ConnectDatabase(); err != nil {
+			log.Printf("❌ Failed to connect to database: %v", err)
+			return 1
+		}
+		defer database.CloseDatabase()
+
+		if err := cmd.run(args[1:]); err != nil {
+			log.Printf("❌ %s failed: %v", cmd.name, err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q. Available commands:\n", args[0])
+	for _, cmd := range append(commands, standaloneCommands...) {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", cmd.name, cmd.description)
+	}
+	return 2
+}
+
+// rescoreCommand recomputes prediction points, e.g. "rescore -season 2024 -gameweek 5"
+func rescoreCommand(args []string) error {
+	flags := flag.NewFlagSet("rescore", flag.ContinueOnError)
+	league := flags.String("league", "", "league to re-score (default: all leagues)")
+	season := flags.String("season", "", "season to re-score (default: all seasons)")
+	gameWeek := flags.Int("gameweek", 0, "gameweek to re-score (requires -season)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *gameWeek != 0 && *season == "" {
+		return fmt.Errorf("-season is required when re-scoring a gameweek")
+	}
+
+	summary, err := settlement.Rescore(settlement.Scope{League: *league, Season: *season, MatchDay: *gameWeek})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Re-scored %d matches: %d of %d predictions changed\n",
+		summary.Matches, summary.Updated, summary.Predictions)
+	return nil
+}
+
+// rebuildStandingsCommand recomputes the materialized leaderboard from scratch
+func rebuildStandingsCommand(args []string) error {
+	flags := flag.NewFlagSet("rebuild-standings", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	rebuilt, err := leaderboard.RebuildStandings()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Rebuilt standings for %d users\n", rebuilt)
+	return nil
+}
+
+// createAdminCommand bootstraps an admin, e.g. "create-admin -username alice -email alice@example.com -password ...".
+// An existing user matching -username or -email is promoted and keeps their password.
+func createAdminCommand(args []string) error {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	username := flags.String("username", "", "username of the admin")
+	email := flags.String("email", "", "email of the admin")
+	password := flags.String("password", "", "password for a new account")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *username == "" && *email == "" {
+		return fmt.Errorf("-username or -email is required")
+	}
+
+	var user models.User
+	err := database.DB.Where("username = ? OR email = ?", *username, *email).First(&user).Error
+	if err == nil {
+		if err := database.DB.Model(&user).Update("role", models.RoleAdmin).Error; err != nil {
+			return err
+		}
+		fmt.Printf("%s is now an admin\n", user.Username)
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	req := controllers.RegisterRequest{Username: *username, Email: *email, Password: *password}
+	if err := controllers.ValidateRegistration(req); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), security.PasswordCost)
+	if err != nil {
+		return err
+	}
+
+	user = models.User{
+		Username: req.Username,
+		Email:    req.Email,
+		Password: string(hashedPassword),
+		Role:     models.RoleAdmin,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		return err
+	}
+
+	fmt.Printf("Created admin %s\n", user.Username)
+	return nil
+}
+
+// fakeIDPCommand serves a fake OpenID Connect provider until interrupted, e.g.
+// "fake-idp -addr :9400 -email fan@example.com", for OIDC_ISSUER=http://localhost:9400
+func fakeIDPCommand(args []string) error {
+	flags := flag.NewFlagSet("fake-idp", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:9400", "address to listen on")
+	issuer := flags.String("issuer", "", "issuer URL (default: http://<addr>)")
+	clientID := flags.String("client-id", "ball-knowledge", "client ID to accept")
+	clientSecret := flags.String("client-secret", "", "client secret to require (default: none, PKCE only)")
+	subject := flags.String("sub", "fake-user-1", "subject of the signed in user")
+	email := flags.String("email", "fan@example.com", "email of the signed in user")
+	unverified := flags.Bool("unverified", false, "report the email as unverified")
+	name := flags.String("name", "Test Fan", "name of the signed in user")
+	username := flags.String("username", "", "preferred_username of the signed in user")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *issuer == "" {
+		*issuer = "http://" + *addr
+	}
+
+	provider, err := fakeidp.New(fakeidp.Options{
+		Issuer:       *issuer,
+		ClientID:     *clientID,
+		ClientSecret: *clientSecret,
+		User: fakeidp.User{
+			Subject:       *subject,
+			Email:         *email,
+			EmailVerified: !*unverified,
+			Name:          *name,
+			Username:      *username,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("🔑 Fake OIDC provider at %s, signing everyone in as %s (add login_hint=<email> to pick another)", *issuer, *email)
+	log.Printf("   Set OIDC_ISSUER=%s OIDC_CLIENT_ID=%s", *issuer, *clientID)
+	return http.ListenAndServe(*addr, provider.Handler())
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String returns the environment variable or the default value
+func String(key, defaultValue string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// Int returns the environment variable parsed as an integer or the default value
+func Int(key string, defaultValue int) int {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("⚠️  Warning: invalid integer for %s (%q), using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// Bool returns the environment variable parsed as a boolean or the default value
+func Bool(key string, defaultValue bool) bool {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("⚠️  Warning: invalid boolean for %s (%q), using default %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// Duration returns the environment variable parsed as a time.Duration or the default value.
+// Values such as "0", "off" and "disabled" are treated as a zero duration.
+func Duration(key string, defaultValue time.Duration) time.Duration {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+
+	switch strings.ToLower(value) {
+	case "0", "off", "disabled", "false":
+		return 0
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("⚠️  Warning: invalid duration for %s (%q), using default %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// List returns the environment variable split on commas, with empty entries removed
+func List(key string, defaultValue []string) []string {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
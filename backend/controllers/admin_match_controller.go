@@ -0,0 +1,364 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ball-knowledge/audit"
+	"ball-knowledge/database"
+	"ball-knowledge/leaderboard"
+	"ball-knowledge/models"
+	"ball-knowledge/settlement"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type UpdateMatchRequest struct {
+	HomeTeam *string    `json:"home_team"`
+	AwayTeam *string    `json:"away_team"`
+	Date     *time.Time `json:"date"`
+	League   *string    `json:"league"`
+	Season   *string    `json:"season"`
+	MatchDay *int       `json:"match_day" binding:"omitempty,min=0"`
+}
+
+type SetResultRequest struct {
+	HomeScore *int `json:"home_score" binding:"required,min=0"`
+	AwayScore *int `json:"away_score" binding:"required,min=0"`
+}
+
+type UpdateStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Force  bool   `json:"force"` // Admins only: allow a transition the status lifecycle forbids
+}
+
+type RescheduleRequest struct {
+	Date     time.Time `json:"date" binding:"required"`
+	MatchDay *int      `json:"match_day" binding:"omitempty,min=0"`
+}
+
+// UpdateMatch edits a match's teams, kickoff, competition or gameweek (moderator function)
+func UpdateMatch(c *gin.Context) {
+	var req UpdateMatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, ok := loadMatchForEdit(c)
+	if !ok {
+		return
+	}
+
+	updated := existing
+	if req.HomeTeam != nil {
+		updated.HomeTeam = *req.HomeTeam
+	}
+	if req.AwayTeam != nil {
+		updated.AwayTeam = *req.AwayTeam
+	}
+	if req.Date != nil {
+		updated.Date = req.Date.UTC()
+	}
+	if req.League != nil {
+		updated.League = *req.League
+	}
+	if req.Season != nil {
+		updated.Season = *req.Season
+	}
+	if req.MatchDay != nil {
+		updated.MatchDay = *req.MatchDay
+	}
+	if updated.HomeTeam == "" || updated.AwayTeam == "" || updated.League == "" || updated.Season == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "teams, league and season cannot be empty"})
+		return
+	}
+
+	saveMatchEdit(c, existing, updated, audit.ActionMatchUpdate)
+}
+
+// SetMatchResult sets or corrects a match's final score, finishing the match (moderator function)
+func SetMatchResult(c *gin.Context) {
+	var req SetResultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, ok := loadMatchForEdit(c)
+	if !ok {
+		return
+	}
+
+	updated := existing
+	updated.Result = fmt.Sprintf("%d:%d", *req.HomeScore, *req.AwayScore)
+	if err := updated.TransitionTo(models.MatchStatusFinished); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	saveMatchEdit(c, existing, updated, audit.ActionMatchResult)
+}
+
+// UpdateMatchStatus moves a match through its lifecycle, e.g. to postpone or cancel it
+// (moderator function). Admins can force a transition the lifecycle forbids, such as
+// reopening a match a provider wrongly reported as finished; leaving finished clears the result.
+func UpdateMatchStatus(c *gin.Context) {
+	var req UpdateStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsValidMatchStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid status %q", req.Status)})
+		return
+	}
+	if req.Force && !models.HasRole(c.GetString("role"), models.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can force a status change"})
+		return
+	}
+
+	existing, ok := loadMatchForEdit(c)
+	if !ok {
+		return
+	}
+
+	updated := existing
+	if err := updated.TransitionTo(req.Status); err != nil {
+		if !req.Force {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		updated.Status = req.Status
+	}
+	if existing.Status == models.MatchStatusFinished && updated.Status != models.MatchStatusFinished &&
+		updated.Status != models.MatchStatusCancelled {
+		updated.Result = ""
+	}
+
+	saveMatchEdit(c, existing, updated, audit.ActionMatchStatus)
+}
+
+// RescheduleMatch moves a match to a new kickoff, and optionally gameweek. A postponed
+// match becomes scheduled again (moderator function).
+func RescheduleMatch(c *gin.Context) {
+	var req RescheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, ok := loadMatchForEdit(c)
+	if !ok {
+		return
+	}
+	if existing.Status == models.MatchStatusFinished || existing.Status == models.MatchStatusCancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot reschedule a %s match", existing.Status)})
+		return
+	}
+
+	updated := existing
+	updated.Date = req.Date.UTC()
+	if req.MatchDay != nil {
+		updated.MatchDay = *req.MatchDay
+	}
+	if updated.Status == models.MatchStatusPostponed {
+		updated.Status = models.MatchStatusScheduled
+	}
+
+	saveMatchEdit(c, existing, updated, audit.ActionMatchReschedule)
+}
+
+// UnlockMatch lets fixture syncs update a manually edited match again (moderator function)
+func UnlockMatch(c *gin.Context) {
+	existing, ok := loadMatchForEdit(c)
+	if !ok {
+		return
+	}
+
+	updated := existing
+	updated.Locked = false
+	saveMatchEdit(c, existing, updated, audit.ActionMatchUnlock)
+}
+
+// DeleteMatch deletes a match and its predictions (admin function)
+func DeleteMatch(c *gin.Context) {
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	match, ok := loadMatchForEdit(c)
+	if !ok {
+		return
+	}
+
+	var predictions []models.Prediction
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("match_id = ?", match.ID).Find(&predictions).Error; err != nil {
+			return err
+		}
+		for i := range predictions {
+			if err := leaderboard.UpdateStanding(tx, predictions[i].UserID, &predictions[i], nil); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("match_id = ?", match.ID).Delete(&models.Prediction{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&match).Error; err != nil {
+			return err
+		}
+		if len(predictions) > 0 {
+			if err := leaderboard.InvalidateSnapshots(tx, match.Date); err != nil {
+				return err
+			}
+		}
+
+		return audit.Record(tx, &actorID, audit.ActionMatchDelete, audit.EntityMatch, match.ID.String(), gin.H{
+			"match":       match,
+			"predictions": len(predictions),
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete match"})
+		return
+	}
+
+	if err := settlement.RecordSnapshots(); err != nil {
+		log.Printf("⚠️  Failed to record rank snapshots: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Match deleted",
+		"deleted_predictions": len(predictions),
+	})
+}
+
+// GetAuditLog returns the most recent audited actions, optionally filtered by
+// action, entity or actor (admin function)
+func GetAuditLog(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+
+	query := database.DB.Order("created_at DESC").Limit(limit)
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if entityType := c.Query("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID := c.Query("entity_id"); entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+	}
+	if actorID := c.Query("actor_id"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+
+	var entries []models.AuditLog
+	if err := query.Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  entries,
+		"count": len(entries),
+	})
+}
+
+// loadMatchForEdit loads the match in the :id path parameter
+func loadMatchForEdit(c *gin.Context) (models.Match, bool) {
+	var match models.Match
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&match).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Match not found"})
+		return match, false
+	}
+	return match, true
+}
+
+// saveMatchEdit stores a manual edit to a match. Changed fields go to the match change
+// log and the edit to the audit log. The match is locked against fixture syncs unless
+// the edit unlocks it, and its predictions are re-scored if a change affects scoring.
+func saveMatchEdit(c *gin.Context, existing, updated models.Match, action string) {
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	if action != audit.ActionMatchUnlock {
+		updated.Locked = true
+	}
+
+	changes := models.DiffMatch(existing, updated)
+	if len(changes) == 0 && updated.Locked == existing.Locked {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "No changes",
+			"match":   existing,
+		})
+		return
+	}
+
+	var summary settlement.Summary
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&updated).Error; err != nil {
+			return err
+		}
+
+		fields := make([]gin.H, 0, len(changes))
+		for i := range changes {
+			changes[i].MatchID = existing.ID
+			changes[i].UserID = &actorID
+			fields = append(fields, gin.H{"field": changes[i].Field, "old": changes[i].OldValue, "new": changes[i].NewValue})
+		}
+		if len(changes) > 0 {
+			if err := tx.Create(&changes).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := audit.Record(tx, &actorID, action, audit.EntityMatch, existing.ID.String(), gin.H{"changes": fields}); err != nil {
+			return err
+		}
+
+		// Moving a finished match changes which rank snapshots include its points
+		if existing.Status == models.MatchStatusFinished && !existing.Date.Equal(updated.Date) {
+			earliest := existing.Date
+			if updated.Date.Before(earliest) {
+				earliest = updated.Date
+			}
+			if err := leaderboard.InvalidateSnapshots(tx, earliest); err != nil {
+				return err
+			}
+		}
+
+		if models.AffectsScoring(changes) {
+			var err error
+			summary, err = settlement.SettleMatch(tx, existing.ID)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update match"})
+		return
+	}
+
+	if err := settlement.RecordSnapshots(); err != nil {
+		log.Printf("⚠️  Failed to record rank snapshots: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Match updated",
+		"match":    updated,
+		"changes":  changes,
+		"rescored": summary,
+	})
+}
@@ -1,50 +1,67 @@
 package controllers
 
 import (
-	"crypto/rand"
-	"encoding/hex"
 	"errors"
+	"log"
 	"net/http"
-	"os"
 	"regexp"
-	"time"
+	"strconv"
 
+	"ball-knowledge/accounts"
+	"ball-knowledge/auth"
 	"ball-knowledge/database"
 	"ball-knowledge/models"
+	"ball-knowledge/security"
+	"ball-knowledge/sessions"
+	"ball-knowledge/twofactor"
 
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type Claims struct {
-	UserID string `json:"user_id"`
-	jwt.RegisteredClaims
-}
-
 type LoginRequest struct {
 	UsernameOrEmail string `json:"usernameOrEmail" binding:"required"`
 	Password        string `json:"password" binding:"required"`
 }
 
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+	All          bool   `json:"all"` // Also end the user's other logins
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required"`
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
 
-var jwtKey = []byte(getJWTSecret())
-
-func getJWTSecret() string {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		// Generate a random secret for development
-		bytes := make([]byte, 32)
-		rand.Read(bytes)
-		secret = hex.EncodeToString(bytes)
-		println("⚠️  WARNING: Using random JWT secret. Set JWT_SECRET environment variable for production!")
-	}
-	return secret
+// ValidateRegistration checks a new account's username, email and password,
+// for accounts created outside the API such as by the create-admin command
+func ValidateRegistration(req RegisterRequest) error {
+	return validateUserInput(req)
 }
 
 // validateUserInput validates registration input
@@ -61,8 +78,8 @@ func validateUserInput(req RegisterRequest) error {
 	}
 
 	// Password validation
-	if len(req.Password) < 8 {
-		return errors.New("password must be at least 8 characters")
+	if err := validatePassword(req.Password); err != nil {
+		return err
 	}
 
 	// Email validation
@@ -74,6 +91,14 @@ func validateUserInput(req RegisterRequest) error {
 	return nil
 }
 
+// validatePassword checks a new password meets the password rules
+func validatePassword(password string) error {
+	if len(password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+	return nil
+}
+
 // RegisterUser handles user registration
 func RegisterUser(c *gin.Context) {
 	var req RegisterRequest
@@ -96,7 +121,7 @@ func RegisterUser(c *gin.Context) {
 	}
 
 	// Hash password with higher cost for production
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), 12)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), security.PasswordCost)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 		return
@@ -107,6 +132,7 @@ func RegisterUser(c *gin.Context) {
 		Username: req.Username,
 		Email:    req.Email,
 		Password: string(hashedPassword),
+		Role:     models.RoleUser,
 	}
 
 	if err := database.DB.Create(&user).Error; err != nil {
@@ -114,23 +140,27 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := generateJWTToken(user.ID.String())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	// The account works right away; the link only proves the address is theirs
+	if err := accounts.SendVerification(user); err != nil {
+		log.Printf("⚠️  Failed to send verification email to user %s: %v", user.ID, err)
+	}
+
+	// Generate access and refresh tokens
+	response, ok := issueTokens(c, user, false)
+	if !ok {
 		return
 	}
 
 	// Return success response (don't return password)
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "User created successfully",
-		"token":   token,
-		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
-		},
-	})
+	response["message"] = "User created successfully"
+	response["user"] = gin.H{
+		"id":             user.ID,
+		"username":       user.Username,
+		"email":          user.Email,
+		"role":           user.Role,
+		"email_verified": user.EmailVerified,
+	}
+	c.JSON(http.StatusCreated, response)
 }
 
 // LoginUser handles user login
@@ -143,33 +173,146 @@ func LoginUser(c *gin.Context) {
 
 	// Find user by username or email
 	var user models.User
-	if err := database.DB.Where("username = ? OR email = ?", req.UsernameOrEmail, req.UsernameOrEmail).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	found := database.DB.Where("username = ? OR email = ?", req.UsernameOrEmail, req.UsernameOrEmail).First(&user).Error == nil
+
+	var userID *uuid.UUID
+	keys := security.NewLoginKeys("", req.UsernameOrEmail, c.ClientIP())
+	if found {
+		userID = &user.ID
+		keys = security.NewLoginKeys(user.ID.String(), req.UsernameOrEmail, c.ClientIP())
+	}
+
+	// Refuse attempts while the account or IP is backing off or locked out
+	wait, err := security.CheckLogin(keys)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		return
+	}
+	if wait > 0 {
+		security.RecordEvent(security.EventLoginThrottled, userID, c.ClientIP(), gin.H{"identifier": req.UsernameOrEmail})
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, try again later"})
 		return
 	}
 
-	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	// Verify password; unknown accounts are checked against a dummy hash so they take as long
+	if found {
+		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
+	} else {
+		security.CompareDummyPassword(req.Password)
+		err = bcrypt.ErrMismatchedHashAndPassword
+	}
+	if err != nil {
+		locked, throttleErr := security.LoginFailed(keys)
+		if throttleErr != nil {
+			log.Printf("⚠️  Failed to record failed login: %v", throttleErr)
+		}
+		security.RecordEvent(security.EventLoginFailed, userID, c.ClientIP(), gin.H{"identifier": req.UsernameOrEmail})
+		if locked {
+			security.RecordEvent(security.EventAccountLocked, userID, c.ClientIP(), gin.H{"identifier": req.UsernameOrEmail})
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Generate JWT token
-	token, err := generateJWTToken(user.ID.String())
+	// With two-factor authentication failures are cleared once the code is right,
+	// so knowing the password doesn't reset code guesses
+	if !user.TOTPEnabled {
+		if err := security.LoginSucceeded(keys); err != nil {
+			log.Printf("⚠️  Failed to clear failed logins for user %s: %v", user.ID, err)
+		}
+	}
+
+	response, ok := completeFirstFactor(c, user)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// completeFirstFactor finishes a login whose first factor, a password or an identity
+// provider, succeeded. Users with two-factor authentication get a challenge for their
+// code; others get tokens. It responds with an error itself on failure.
+func completeFirstFactor(c *gin.Context, user models.User) (gin.H, bool) {
+	if user.TOTPEnabled {
+		challenge, err := auth.IssueTwoFactorChallenge(user.ID.String(), twofactor.ChallengeTTL())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+			return nil, false
+		}
+		return gin.H{
+			"message":             "Enter your two-factor authentication code",
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+			"expires_in":          int(twofactor.ChallengeTTL().Seconds()),
+		}, true
+	}
+
+	// Generate access and refresh tokens
+	response, ok := issueTokens(c, user, false)
+	if !ok {
+		return nil, false
+	}
+
+	response["message"] = "Login successful"
+	response["user"] = loginUserResponse(user)
+	if auth.RequiresTwoFactor(user.Role) {
+		// Role-restricted routes stay closed until two-factor authentication is set up
+		response["two_factor_setup_required"] = true
+	}
+	return response, true
+}
+
+// LoginTwoFactor completes a login with two-factor authentication, exchanging the
+// challenge token from LoginUser and a TOTP or recovery code for access tokens
+func LoginTwoFactor(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := auth.ParseTwoFactorChallenge(req.ChallengeToken)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge, please log in again"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"token":   token,
-		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
-		},
-	})
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge, please log in again"})
+		return
+	}
+
+	keys := security.NewLoginKeys(user.ID.String(), "", c.ClientIP())
+	if !verifyTwoFactorCode(c, user, req.Code, keys) {
+		return
+	}
+
+	if err := security.LoginSucceeded(keys); err != nil {
+		log.Printf("⚠️  Failed to clear failed logins for user %s: %v", user.ID, err)
+	}
+
+	response, ok := issueTokens(c, user, true)
+	if !ok {
+		return
+	}
+
+	response["message"] = "Login successful"
+	response["user"] = loginUserResponse(user)
+	c.JSON(http.StatusOK, response)
+}
+
+// loginUserResponse is the user returned with a successful login
+func loginUserResponse(user models.User) gin.H {
+	return gin.H{
+		"id":                 user.ID,
+		"username":           user.Username,
+		"email":              user.Email,
+		"role":               user.Role,
+		"email_verified":     user.EmailVerified,
+		"two_factor_enabled": user.TOTPEnabled,
+	}
 }
 
 // GetUserProfile returns the current user's profile
@@ -195,44 +338,246 @@ func GetUserProfile(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"user": gin.H{
-			"id":          user.ID,
-			"username":    user.Username,
-			"email":       user.Email,
-			"total_points": totalPoints,
+			"id":                 user.ID,
+			"username":           user.Username,
+			"email":              user.Email,
+			"role":               user.Role,
+			"email_verified":     user.EmailVerified,
+			"two_factor_enabled": user.TOTPEnabled,
+			"total_points":       totalPoints,
 		},
 	})
 }
 
-// generateJWTToken creates a new JWT token for a user
-func generateJWTToken(userID string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &Claims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+// issueTokens starts a new login for a user, returning a short-lived access token and
+// the refresh token that renews it. mfa marks a login that passed two-factor
+// authentication. It responds with an error itself on failure.
+func issueTokens(c *gin.Context, user models.User, mfa bool) (gin.H, bool) {
+	token, err := auth.IssueAccessToken(user.ID.String(), user.Role, mfa)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return nil, false
+	}
+
+	refreshToken, err := sessions.Issue(user.ID, requestClient(c), mfa)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return nil, false
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	return gin.H{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    int(auth.AccessTokenTTL().Seconds()),
+	}, true
 }
 
-// RefreshToken generates a new token (optional endpoint)
+// requestClient describes the client making a request
+func requestClient(c *gin.Context) sessions.Client {
+	return sessions.Client{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
+
+// RefreshToken exchanges a refresh token for a new access token and refresh token.
+// Refresh tokens are single-use; presenting one twice ends that login.
 func RefreshToken(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	token, err := generateJWTToken(userID.(string))
+	refreshToken, previous, err := sessions.Rotate(req.RefreshToken, requestClient(c))
+	if errors.Is(err, sessions.ErrInvalidToken) || errors.Is(err, sessions.ErrTokenReused) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	// Reload the user so the new token carries their current role
+	var user models.User
+	if err := database.DB.Where("id = ?", previous.UserID).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	// The login keeps its two-factor status, unless two-factor authentication was since turned off
+	token, err := auth.IssueAccessToken(user.ID.String(), user.Role, previous.MFA && user.TOTPEnabled)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"token": token,
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    int(auth.AccessTokenTTL().Seconds()),
 	})
-}
\ No newline at end of file
+}
+
+// Logout revokes a refresh token, or with "all" every refresh token of its user.
+// Access tokens already issued stay valid until they expire.
+func Logout(c *gin.Context) {
+	var req LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := sessions.Revoke(req.RefreshToken)
+	if errors.Is(err, sessions.ErrInvalidToken) {
+		// Nothing to revoke; the client is logged out either way
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+		return
+	}
+	if err == nil && req.All {
+		err = sessions.RevokeAll(database.DB, userID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// GetJWKS publishes the public keys that verify access tokens, for other services
+func GetJWKS(c *gin.Context) {
+	jwks, err := auth.JWKS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load signing keys"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwks)
+}
+
+// ForgotPassword emails a password reset link. It responds the same whether or not
+// the email has an account, so it can't be used to discover accounts.
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Each request sends an email, so clients making many are slowed down
+	wait, err := security.CheckPasswordReset(c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request password reset"})
+		return
+	}
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many password reset requests, try again later"})
+		return
+	}
+	if err := security.PasswordResetRequested(c.ClientIP()); err != nil {
+		log.Printf("⚠️  Failed to record password reset request: %v", err)
+	}
+
+	if err := accounts.RequestPasswordReset(req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request password reset"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If an account uses that email, a password reset link has been sent",
+	})
+}
+
+// ResetPassword sets a new password with a reset token and logs the user out everywhere
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validatePassword(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := accounts.ResetPassword(req.Token, req.Password)
+	if errors.Is(err, accounts.ErrInvalidResetToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	// A reset proves control of the email, so it also lifts a lockout
+	security.RecordEvent(security.EventPasswordReset, &user.ID, c.ClientIP(), nil)
+	if err := security.ClearLockout(security.AccountKey(user.ID.String())); err != nil {
+		log.Printf("⚠️  Failed to clear failed logins for user %s: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset, please log in again"})
+}
+
+// VerifyEmail marks an account's email address as verified using the link from its verification email
+func VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := accounts.VerifyEmail(req.Token)
+	if errors.Is(err, accounts.ErrInvalidVerificationToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email verified",
+		"user": gin.H{
+			"id":             user.ID,
+			"username":       user.Username,
+			"email":          user.Email,
+			"email_verified": true,
+		},
+	})
+}
+
+// ResendVerification sends the current user a new verification link. Resends are throttled.
+func ResendVerification(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err := accounts.ResendVerification(user)
+	var throttled *accounts.ResendThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		c.Header("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": throttled.Error()})
+	case errors.Is(err, accounts.ErrAlreadyVerified):
+		c.JSON(http.StatusConflict, gin.H{"error": "Email address is already verified"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+	}
+}
@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ball-knowledge/config"
+	"ball-knowledge/leaderboard"
+
+	"github.com/gin-gonic/gin"
+)
+
+// leaderboardOptions parses the leaderboard query string: the ranking style
+// (competition or dense) and the filters league, season, gameweek and month
+// (YYYY-MM, in the "tz" timezone).
+// Gameweek numbers repeat every season, so a gameweek without a season means the current SEASON.
+// It returns the options and a description of the period for the response, or nil for all-time standings.
+func leaderboardOptions(c *gin.Context) (leaderboard.Options, gin.H, error) {
+	opts := leaderboard.Options{
+		League:  c.Query("league"),
+		Season:  c.Query("season"),
+		Ranking: c.DefaultQuery("ranking", leaderboard.RankingCompetition),
+	}
+	if !leaderboard.IsValidRanking(opts.Ranking) {
+		return opts, nil, fmt.Errorf("invalid ranking %q: use %q or %q", opts.Ranking, leaderboard.RankingCompetition, leaderboard.RankingDense)
+	}
+
+	if value := c.Query("gameweek"); value != "" {
+		gameWeek, err := strconv.Atoi(value)
+		if err != nil || gameWeek < 1 {
+			return opts, nil, fmt.Errorf("invalid gameweek %q", value)
+		}
+		opts.GameWeek = gameWeek
+		if opts.Season == "" {
+			opts.Season = config.String("SEASON", "")
+		}
+	}
+
+	month := c.Query("month")
+	if month != "" {
+		loc, err := requestTimezone(c)
+		if err != nil {
+			return opts, nil, err
+		}
+		start, err := time.ParseInLocation("2006-01", month, loc)
+		if err != nil {
+			return opts, nil, fmt.Errorf("invalid month %q: use YYYY-MM", month)
+		}
+		opts.From = start
+		opts.To = start.AddDate(0, 1, 0)
+	}
+
+	var period gin.H
+	switch {
+	case opts.GameWeek > 0:
+		period = gin.H{"type": "gameweek", "gameweek": opts.GameWeek}
+	case month != "":
+		period = gin.H{"type": "month", "month": month, "from": opts.From.UTC(), "to": opts.To.UTC()}
+	case opts.Season != "":
+		period = gin.H{"type": "season"}
+	case opts.League != "":
+		period = gin.H{"type": "competition"}
+	default:
+		return opts, nil, nil
+	}
+	if opts.League != "" {
+		period["league"] = opts.League
+	}
+	if opts.Season != "" {
+		period["season"] = opts.Season
+	}
+	return opts, period, nil
+}
+
+// describeLeaderboard adds how the leaderboard was ranked to the response and, for a
+// period, the period and its winners. Winners are provisional until every match in
+// the period has been settled.
+func describeLeaderboard(response gin.H, opts leaderboard.Options, period gin.H) error {
+	response["ranking"] = opts.Ranking
+	response["tiebreakers"] = leaderboard.Tiebreakers()
+
+	if period == nil {
+		return nil
+	}
+
+	complete, err := leaderboard.Complete(opts)
+	if err != nil {
+		return err
+	}
+	winners, err := leaderboard.Winners(opts)
+	if err != nil {
+		return err
+	}
+
+	response["period"] = period
+	response["winners"] = winners
+	response["complete"] = complete
+	return nil
+}
+
+// writeLeaderboard responds with one page of a leaderboard under key, along with the
+// total number of participants. extra fields are added to the response.
+func writeLeaderboard(c *gin.Context, opts leaderboard.Options, period gin.H, key string, extra gin.H) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be at least 1"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+
+	pageEntries, total, err := leaderboard.Page(opts, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve leaderboard"})
+		return
+	}
+
+	response := gin.H{
+		key:     pageEntries,
+		"count": len(pageEntries),
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	}
+	for k, v := range extra {
+		response[k] = v
+	}
+	if err := describeLeaderboard(response, opts, period); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve leaderboard"})
+		return
+	}
+
+	writeCacheable(c, response)
+}
+
+// writeLeaderboardPosition responds with userID's entry on a leaderboard and the
+// entries around it; "neighbours" sets how many either side (default 2)
+func writeLeaderboardPosition(c *gin.Context, opts leaderboard.Options, period gin.H, userID string, extra gin.H) {
+	n, err := strconv.Atoi(c.DefaultQuery("neighbours", "2"))
+	if err != nil || n < 0 || n > 25 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "neighbours must be between 0 and 25"})
+		return
+	}
+
+	entry, neighbours, total, ok, err := leaderboard.Around(opts, userID, n)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve leaderboard"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not on this leaderboard"})
+		return
+	}
+
+	response := gin.H{
+		"me":         entry,
+		"neighbours": neighbours,
+		"total":      total,
+	}
+	for k, v := range extra {
+		response[k] = v
+	}
+	if err := describeLeaderboard(response, opts, period); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve leaderboard"})
+		return
+	}
+
+	writeCacheable(c, response)
+}
+
+// writeCacheable responds with a leaderboard that clients and proxies may cache for
+// LEADERBOARD_CACHE_MAX_AGE. The ETag is a hash of the body, so a client revalidating
+// with If-None-Match gets 304 Not Modified until the standings change.
+func writeCacheable(c *gin.Context, response gin.H) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode leaderboard"})
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	// Responses for a signed-in user mustn't be stored by shared caches
+	scope := "public"
+	if _, authenticated := c.Get("userID"); authenticated {
+		scope = "private"
+	}
+	maxAge := config.Duration("LEADERBOARD_CACHE_MAX_AGE", 30*time.Second)
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, int(maxAge.Seconds())))
+
+	if match := c.GetHeader("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
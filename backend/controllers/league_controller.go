@@ -0,0 +1,448 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"ball-knowledge/config"
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Invite codes avoid characters that are easy to confuse when read aloud (0/O, 1/I/L)
+const inviteCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+const inviteCodeLength = 8
+
+type CreateLeagueRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type JoinLeagueRequest struct {
+	InviteCode string `json:"invite_code" binding:"required"`
+}
+
+// LeagueMember is a member as listed on a league's page
+type LeagueMember struct {
+	UserID   string    `json:"user_id"`
+	Username string    `json:"username"`
+	JoinedAt time.Time `json:"joined_at"`
+	IsOwner  bool      `json:"is_owner"`
+}
+
+// MyLeague is a league in the current user's list; only its owner sees the invite code and link
+type MyLeague struct {
+	models.MiniLeague
+	InviteCode string `json:"invite_code,omitempty"`
+	InviteLink string `json:"invite_link,omitempty"`
+}
+
+// CreateLeague creates a private league owned, and joined, by the current user
+func CreateLeague(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var req CreateLeagueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	league := models.MiniLeague{
+		Name:    strings.TrimSpace(req.Name),
+		OwnerID: userID,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		code, err := newInviteCode(tx)
+		if err != nil {
+			return err
+		}
+		league.InviteCode = code
+
+		if err := tx.Create(&league).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.MiniLeagueMember{LeagueID: league.ID, UserID: userID}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create league"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "League created",
+		"league":      league,
+		"invite_code": league.InviteCode,
+		"invite_link": inviteLink(league.InviteCode),
+	})
+}
+
+// GetMyLeagues lists the leagues the current user belongs to
+func GetMyLeagues(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var leagues []models.MiniLeague
+	if err := database.DB.
+		Joins("JOIN mini_league_members ON mini_league_members.league_id = mini_leagues.id").
+		Where("mini_league_members.user_id = ?", userID).
+		Order("mini_leagues.name ASC").
+		Find(&leagues).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve leagues"})
+		return
+	}
+
+	data := make([]MyLeague, 0, len(leagues))
+	for _, league := range leagues {
+		entry := MyLeague{MiniLeague: league}
+		if league.OwnerID == userID {
+			entry.InviteCode = league.InviteCode
+			entry.InviteLink = inviteLink(league.InviteCode)
+		}
+		data = append(data, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  data,
+		"count": len(data),
+	})
+}
+
+// GetLeague returns a league and its members; only members can see it
+func GetLeague(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	league, ok := loadLeagueForMember(c, userID)
+	if !ok {
+		return
+	}
+
+	var memberships []models.MiniLeagueMember
+	if err := database.DB.Preload("User").
+		Where("league_id = ?", league.ID).
+		Order("joined_at ASC").
+		Find(&memberships).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve league members"})
+		return
+	}
+	members := make([]LeagueMember, 0, len(memberships))
+	for _, m := range memberships {
+		members = append(members, LeagueMember{
+			UserID:   m.UserID.String(),
+			Username: m.User.Username,
+			JoinedAt: m.JoinedAt,
+			IsOwner:  m.UserID == league.OwnerID,
+		})
+	}
+
+	response := gin.H{
+		"league":  league,
+		"members": members,
+		"count":   len(members),
+	}
+	if league.OwnerID == userID {
+		response["invite_code"] = league.InviteCode
+		response["invite_link"] = inviteLink(league.InviteCode)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// JoinLeague adds the current user to the league with the given invite code.
+// The code comes from the request body, or from the path when following an invite link.
+func JoinLeague(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	code := c.Param("code")
+	if code == "" {
+		var req JoinLeagueRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		code = req.InviteCode
+	}
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	var league models.MiniLeague
+	if err := database.DB.Where("invite_code = ?", code).First(&league).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid invite code"})
+		return
+	}
+
+	if isLeagueMember(league.ID, userID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Already a member of this league"})
+		return
+	}
+
+	if err := database.DB.Create(&models.MiniLeagueMember{LeagueID: league.ID, UserID: userID}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join league"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Joined league",
+		"league":  league,
+	})
+}
+
+// LeaveLeague removes the current user from a league. Owners delete the league instead.
+func LeaveLeague(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	league, ok := loadLeagueForMember(c, userID)
+	if !ok {
+		return
+	}
+	if league.OwnerID == userID {
+		c.JSON(http.StatusConflict, gin.H{"error": "League owners cannot leave their league; delete it instead"})
+		return
+	}
+
+	if err := database.DB.Where("league_id = ? AND user_id = ?", league.ID, userID).
+		Delete(&models.MiniLeagueMember{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave league"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Left league"})
+}
+
+// RemoveLeagueMember removes a member from a league (league owner only)
+func RemoveLeagueMember(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	league, ok := loadLeagueForOwner(c, userID)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if memberID == league.OwnerID {
+		c.JSON(http.StatusConflict, gin.H{"error": "The league owner cannot be removed"})
+		return
+	}
+
+	result := database.DB.Where("league_id = ? AND user_id = ?", league.ID, memberID).
+		Delete(&models.MiniLeagueMember{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of this league"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// RegenerateInviteCode replaces a league's invite code so old links stop working (league owner only)
+func RegenerateInviteCode(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	league, ok := loadLeagueForOwner(c, userID)
+	if !ok {
+		return
+	}
+
+	code, err := newInviteCode(database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invite code"})
+		return
+	}
+	if err := database.DB.Model(&league).Update("invite_code", code).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update invite code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invite_code": code,
+		"invite_link": inviteLink(code),
+	})
+}
+
+// DeleteLeague deletes a league and its memberships (league owner only).
+// Predictions and points are untouched.
+func DeleteLeague(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	league, ok := loadLeagueForOwner(c, userID)
+	if !ok {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("league_id = ?", league.ID).Delete(&models.MiniLeagueMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&league).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete league"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "League deleted"})
+}
+
+// GetLeagueLeaderboard ranks a league's members by their points, with the same period filters
+// as GetLeaderboard; only members can see it
+func GetLeagueLeaderboard(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	league, ok := loadLeagueForMember(c, userID)
+	if !ok {
+		return
+	}
+
+	opts, period, err := leaderboardOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts.MiniLeagueID = &league.ID
+
+	writeLeaderboard(c, opts, period, "data", gin.H{"league": league.Name})
+}
+
+// GetMyLeagueLeaderboardPosition returns the current user's position on a league's
+// leaderboard and the members around them
+func GetMyLeagueLeaderboardPosition(c *gin.Context) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	league, ok := loadLeagueForMember(c, userID)
+	if !ok {
+		return
+	}
+
+	opts, period, err := leaderboardOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts.MiniLeagueID = &league.ID
+
+	writeLeaderboardPosition(c, opts, period, userID.String(), gin.H{"league": league.Name})
+}
+
+// authenticatedUserID returns the current user's ID, responding with 401 when there isn't one
+func authenticatedUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// loadLeagueForMember loads the league in the :id path parameter if userID belongs to it.
+// Non-members get a 404 so private leagues can't be discovered by ID.
+func loadLeagueForMember(c *gin.Context, userID uuid.UUID) (models.MiniLeague, bool) {
+	var league models.MiniLeague
+
+	leagueID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid league ID"})
+		return league, false
+	}
+
+	if err := database.DB.Where("id = ?", leagueID).First(&league).Error; err != nil || !isLeagueMember(league.ID, userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "League not found"})
+		return league, false
+	}
+	return league, true
+}
+
+// loadLeagueForOwner loads the league in the :id path parameter if userID owns it
+func loadLeagueForOwner(c *gin.Context, userID uuid.UUID) (models.MiniLeague, bool) {
+	league, ok := loadLeagueForMember(c, userID)
+	if !ok {
+		return league, false
+	}
+	if league.OwnerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the league owner can do this"})
+		return league, false
+	}
+	return league, true
+}
+
+func isLeagueMember(leagueID, userID uuid.UUID) bool {
+	var count int64
+	database.DB.Model(&models.MiniLeagueMember{}).
+		Where("league_id = ? AND user_id = ?", leagueID, userID).
+		Count(&count)
+	return count > 0
+}
+
+// newInviteCode generates a random invite code that no league uses yet
+func newInviteCode(db *gorm.DB) (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		code := make([]byte, inviteCodeLength)
+		for i := range code {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(inviteCodeAlphabet))))
+			if err != nil {
+				return "", err
+			}
+			code[i] = inviteCodeAlphabet[n.Int64()]
+		}
+
+		var count int64
+		if err := db.Model(&models.MiniLeague{}).Where("invite_code = ?", string(code)).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return string(code), nil
+		}
+	}
+	return "", errors.New("could not generate a unique invite code")
+}
+
+// inviteLink is the URL members share to invite others, served by the frontend at APP_BASE_URL
+func inviteLink(code string) string {
+	return strings.TrimRight(config.String("APP_BASE_URL", "http://localhost:3000"), "/") + "/leagues/join/" + code
+}
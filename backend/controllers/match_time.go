@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"ball-knowledge/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestTimezone returns the location named by the "tz" query parameter, or UTC
+func requestTimezone(c *gin.Context) (*time.Location, error) {
+	name := c.Query("tz")
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	return loc, nil
+}
+
+// parseTimeParam parses an RFC3339 timestamp or a YYYY-MM-DD date in loc.
+// Dates mean the start of that day, or the start of the next day when endOfDay is set,
+// so "to=2024-08-31" includes the whole of the 31st.
+func parseTimeParam(value string, loc *time.Location, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use RFC3339 or YYYY-MM-DD", value)
+	}
+	if endOfDay {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day.UTC(), nil
+}
+
+// renderInTimezone converts kickoff times to loc for the response
+func renderInTimezone(matches []models.Match, loc *time.Location) {
+	for i := range matches {
+		matches[i].Date = matches[i].Date.In(loc)
+	}
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"ball-knowledge/accounts"
+	"ball-knowledge/config"
+	"ball-knowledge/oidc"
+	"ball-knowledge/security"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcStateCookie ties a login's state to the browser that started it, so an attacker
+// can't finish their own login in someone else's browser (login CSRF)
+const (
+	oidcStateCookie = "oidc_state"
+	oidcCookiePath  = "/api/auth/oidc"
+)
+
+// GetOIDCProvider tells clients whether to offer OIDC login, and where to start it
+func GetOIDCProvider(c *gin.Context) {
+	if !oidc.Enabled() {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":   true,
+		"name":      oidc.ProviderName(),
+		"login_url": oidcCookiePath + "/login",
+	})
+}
+
+// StartOIDCLogin redirects the browser to the identity provider to sign in. An optional
+// ?login_hint= email is passed on to suggest the account.
+func StartOIDCLogin(c *gin.Context) {
+	authURL, state, err := oidc.Begin(c.Request.Context(), c.Query("login_hint"))
+	if errors.Is(err, oidc.ErrNotConfigured) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to start OIDC login: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(oidc.LoginTTL().Seconds()), oidcCookiePath, "", secureRequest(c), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback finishes a login when the identity provider redirects back. The account is
+// found, linked by verified email or created, and logged in like a password login.
+func OIDCCallback(c *gin.Context) {
+	cookieState, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, oidcCookiePath, "", secureRequest(c), true)
+
+	if providerError := c.Query("error"); providerError != "" {
+		log.Printf("⚠️  Identity provider refused login: %s %s", providerError, c.Query("error_description"))
+		respondOIDC(c, http.StatusUnauthorized, gin.H{"error": "Sign in was cancelled or refused by the identity provider"})
+		return
+	}
+
+	state, code := c.Query("state"), c.Query("code")
+	if state == "" || code == "" {
+		respondOIDC(c, http.StatusBadRequest, gin.H{"error": "Missing state or code"})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		respondOIDC(c, http.StatusBadRequest, gin.H{"error": "Login was started in another browser or has expired, please try again"})
+		return
+	}
+
+	identity, err := oidc.Complete(c.Request.Context(), state, code)
+	switch {
+	case errors.Is(err, oidc.ErrNotConfigured):
+		respondOIDC(c, http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	case errors.Is(err, oidc.ErrInvalidState):
+		respondOIDC(c, http.StatusBadRequest, gin.H{"error": "Login has expired, please try again"})
+		return
+	case err != nil:
+		log.Printf("❌ OIDC login failed: %v", err)
+		respondOIDC(c, http.StatusUnauthorized, gin.H{"error": "Could not sign in with the identity provider"})
+		return
+	}
+
+	login, err := accounts.LoginWithIdentity(identity)
+	switch {
+	case errors.Is(err, accounts.ErrIdentityEmailUnverified):
+		respondOIDC(c, http.StatusForbidden, gin.H{"error": "Your identity provider hasn't verified your email address"})
+		return
+	case errors.Is(err, accounts.ErrAccountEmailUnverified):
+		respondOIDC(c, http.StatusConflict, gin.H{"error": "An account with your email exists; log in with its password and verify your email first"})
+		return
+	case errors.Is(err, accounts.ErrSignupDisabled):
+		respondOIDC(c, http.StatusForbidden, gin.H{"error": "No account uses your email address"})
+		return
+	case err != nil:
+		log.Printf("❌ Failed to log in OIDC identity %s: %v", identity.Subject, err)
+		respondOIDC(c, http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		return
+	}
+
+	user := login.User
+	if login.Linked {
+		security.RecordEvent(security.EventIdentityLinked, &user.ID, c.ClientIP(), gin.H{
+			"issuer":  identity.Issuer,
+			"subject": identity.Subject,
+		})
+	}
+	if login.Created {
+		log.Printf("👤 Created user %s for OIDC identity %s", user.Username, identity.Subject)
+	}
+
+	response, ok := completeFirstFactor(c, user)
+	if !ok {
+		return
+	}
+	response["account_created"] = login.Created
+	respondOIDC(c, http.StatusOK, response)
+}
+
+// respondOIDC answers the browser at the end of an OIDC login. With OIDC_FRONTEND_CALLBACK_URL
+// it redirects there with the response in the URL fragment, which never reaches a server;
+// otherwise it responds with JSON.
+func respondOIDC(c *gin.Context, status int, body gin.H) {
+	target := config.String("OIDC_FRONTEND_CALLBACK_URL", "")
+	if target == "" {
+		c.JSON(status, body)
+		return
+	}
+
+	fragment := url.Values{}
+	for key, value := range body {
+		switch v := value.(type) {
+		case string:
+			fragment.Set(key, v)
+		case int, bool:
+			fragment.Set(key, fmt.Sprint(v))
+		}
+	}
+	c.Redirect(http.StatusFound, target+"#"+fragment.Encode())
+}
+
+// secureRequest reports whether the client reached us over HTTPS, directly or via a proxy
+func secureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
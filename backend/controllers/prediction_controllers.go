@@ -2,14 +2,16 @@ package controllers
 
 import (
 	"net/http"
-	"strconv"
-	"strings"
+	"time"
 
+	"ball-knowledge/accounts"
 	"ball-knowledge/database"
+	"ball-knowledge/leaderboard"
 	"ball-knowledge/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type CreatePredictionRequest struct {
@@ -18,6 +20,25 @@ type CreatePredictionRequest struct {
 	PredictedScoreAway int       `json:"predicted_score_away" binding:"required,min=0"`
 }
 
+// requireVerifiedEmail blocks users with an unverified email when
+// EMAIL_VERIFICATION_REQUIRED_TO_PREDICT is set, responding with 403
+func requireVerifiedEmail(c *gin.Context, userID interface{}) bool {
+	if !accounts.VerifiedEmailRequiredToPredict() {
+		return true
+	}
+
+	var user models.User
+	if err := database.DB.Select("email_verified").Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return false
+	}
+	if !user.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email address to make predictions"})
+		return false
+	}
+	return true
+}
+
 // CreatePrediction handles creating a new prediction
 func CreatePrediction(c *gin.Context) {
 	userID, exists := c.Get("userID")
@@ -26,6 +47,10 @@ func CreatePrediction(c *gin.Context) {
 		return
 	}
 
+	if !requireVerifiedEmail(c, userID) {
+		return
+	}
+
 	var req CreatePredictionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -52,16 +77,26 @@ func CreatePrediction(c *gin.Context) {
 		return
 	}
 
+	if !ensurePredictionsOpen(c, match) {
+		return
+	}
+
 	// Create prediction
 	prediction := models.Prediction{
 		UserID:             userUUID,
 		MatchID:            req.MatchID,
 		PredictedScoreHome: req.PredictedScoreHome,
 		PredictedScoreAway: req.PredictedScoreAway,
-		Points:             CalculatePoints(req.PredictedScoreHome, req.PredictedScoreAway, match.Result),
+		// Points are awarded by settlement once the match finishes
 	}
 
-	if err := database.DB.Create(&prediction).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&prediction).Error; err != nil {
+			return err
+		}
+		return leaderboard.UpdateStanding(tx, userUUID, nil, &prediction)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create prediction"})
 		return
 	}
@@ -101,14 +136,15 @@ func GetUserPredictions(c *gin.Context) {
 
 	var predictions []struct {
 		models.Prediction
-		HomeTeam string `json:"home_team"`
-		AwayTeam string `json:"away_team"`
-		Date     string `json:"date"`
-		Result   string `json:"result"`
+		HomeTeam string    `json:"home_team"`
+		AwayTeam string    `json:"away_team"`
+		Date     time.Time `json:"date"`
+		Result   string    `json:"result"`
+		Status   string    `json:"status"`
 	}
 
 	if err := database.DB.Table("predictions").
-		Select("predictions.*, matches.home_team, matches.away_team, matches.date, matches.result").
+		Select("predictions.*, matches.home_team, matches.away_team, matches.date, matches.result, matches.status").
 		Joins("LEFT JOIN matches ON matches.id = predictions.match_id").
 		Where("predictions.user_id = ?", userID).
 		Order("matches.date DESC").
@@ -123,7 +159,7 @@ func GetUserPredictions(c *gin.Context) {
 	})
 }
 
-// UpdatePrediction allows updating a prediction (only before match starts)
+// UpdatePrediction allows updating a prediction (only before the prediction deadline)
 func UpdatePrediction(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
@@ -131,6 +167,10 @@ func UpdatePrediction(c *gin.Context) {
 		return
 	}
 
+	if !requireVerifiedEmail(c, userID) {
+		return
+	}
+
 	predictionID := c.Param("id")
 
 	var req CreatePredictionRequest
@@ -146,17 +186,20 @@ func UpdatePrediction(c *gin.Context) {
 		return
 	}
 
-	// Get match to recalculate points
+	// Get match to check the prediction deadline
 	var match models.Match
 	if err := database.DB.Where("id = ?", prediction.MatchID).First(&match).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Match not found"})
 		return
 	}
 
+	if !ensurePredictionsOpen(c, match) {
+		return
+	}
+
 	// Update prediction
 	prediction.PredictedScoreHome = req.PredictedScoreHome
 	prediction.PredictedScoreAway = req.PredictedScoreAway
-	prediction.Points = CalculatePoints(req.PredictedScoreHome, req.PredictedScoreAway, match.Result)
 
 	if err := database.DB.Save(&prediction).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update prediction"})
@@ -169,93 +212,60 @@ func UpdatePrediction(c *gin.Context) {
 	})
 }
 
-// GetLeaderboard returns the leaderboard with user rankings
+// GetLeaderboard returns a page of the leaderboard with user rankings, optionally for one
+// competition, season, gameweek or calendar month
 func GetLeaderboard(c *gin.Context) {
-	var leaderboard []struct {
-		UserID       string `json:"user_id"`
-		Username     string `json:"username"`
-		TotalPoints  int    `json:"total_points"`
-		PredictionCount int `json:"prediction_count"`
-		Rank         int    `json:"rank"`
-	}
-
-	// Get leaderboard data
-	if err := database.DB.Table("predictions").
-		Select("users.id as user_id, users.username, SUM(predictions.points) as total_points, COUNT(predictions.id) as prediction_count").
-		Joins("LEFT JOIN users ON users.id = predictions.user_id").
-		Group("users.id, users.username").
-		Order("total_points DESC").
-		Scan(&leaderboard).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve leaderboard"})
+	opts, period, err := leaderboardOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Add ranking
-	for i := range leaderboard {
-		leaderboard[i].Rank = i + 1
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"leaderboard": leaderboard,
-		"count":       len(leaderboard),
-	})
+	writeLeaderboard(c, opts, period, "leaderboard", nil)
 }
 
-// CalculatePoints calculates points based on prediction accuracy
-func CalculatePoints(predictedHome, predictedAway int, actualResult string) int {
-	if actualResult == "" || actualResult == "0:0" {
-		return 0 // No points if match hasn't been played
-	}
-
-	// Parse actual result
-	parts := strings.Split(actualResult, ":")
-	if len(parts) != 2 {
-		return 0
+// GetMyLeaderboardPosition returns the current user's rank and the users around them
+func GetMyLeaderboardPosition(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
 	}
 
-	actualHome, err1 := strconv.Atoi(parts[0])
-	actualAway, err2 := strconv.Atoi(parts[1])
-	if err1 != nil || err2 != nil {
-		return 0
+	opts, period, err := leaderboardOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	points := 0
-
-	// Exact score: 10 points
-	if predictedHome == actualHome && predictedAway == actualAway {
-		points += 10
-	}
+	writeLeaderboardPosition(c, opts, period, userID.(string), nil)
+}
 
-	// Correct result (win/draw/loss): 5 points
-	predictedResult := getMatchResult(predictedHome, predictedAway)
-	actualMatchResult := getMatchResult(actualHome, actualAway)
-	if predictedResult == actualMatchResult {
-		points += 5
+// GetRankHistory returns a user's overall rank and points after each completed gameweek,
+// optionally for one league and season
+func GetRankHistory(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
 	}
 
-	// Correct total goals: 3 points
-	predictedTotal := predictedHome + predictedAway
-	actualTotal := actualHome + actualAway
-	if predictedTotal == actualTotal {
-		points += 3
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
 	}
 
-	// Correct goal difference: 2 points
-	predictedDiff := predictedHome - predictedAway
-	actualDiff := actualHome - actualAway
-	if predictedDiff == actualDiff {
-		points += 2
+	history, err := leaderboard.History(userID, c.Query("league"), c.Query("season"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve rank history"})
+		return
 	}
 
-	return points
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":  user.ID,
+		"username": user.Username,
+		"data":     history,
+		"count":    len(history),
+	})
 }
-
-// getMatchResult determines the match result (home win, away win, or draw)
-func getMatchResult(homeScore, awayScore int) string {
-	if homeScore > awayScore {
-		return "home_win"
-	} else if awayScore > homeScore {
-		return "away_win"
-	}
-	return "draw"
-}
\ No newline at end of file
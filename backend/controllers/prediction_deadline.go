@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"ball-knowledge/config"
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// predictionDeadline returns the moment predictions on a match lock.
+//
+// By default that is the match's kickoff. With PREDICTION_GAMEWEEK_DEADLINE
+// enabled it is the first kickoff of the match's gameweek instead, ignoring
+// postponed and cancelled matches. Either is brought forward by
+// PREDICTION_LOCK_OFFSET (e.g. "1h").
+func predictionDeadline(match models.Match) (time.Time, error) {
+	kickoff := match.Date
+
+	if config.Bool("PREDICTION_GAMEWEEK_DEADLINE", false) {
+		var dates []time.Time
+		if err := database.DB.Model(&models.Match{}).
+			Where("league = ? AND season = ? AND match_day = ?", match.League, match.Season, match.MatchDay).
+			Where("status IN ?", models.ScheduledMatchStatuses).
+			Pluck("date", &dates).Error; err != nil {
+			return time.Time{}, fmt.Errorf("failed to load gameweek kickoffs: %v", err)
+		}
+
+		for _, date := range dates {
+			if date.Before(kickoff) {
+				kickoff = date
+			}
+		}
+	}
+
+	return kickoff.Add(-config.Duration("PREDICTION_LOCK_OFFSET", 0)), nil
+}
+
+// ensurePredictionsOpen writes an error response and returns false if predictions on match are closed.
+// Predictions lock at the deadline, whether creating or editing.
+func ensurePredictionsOpen(c *gin.Context, match models.Match) bool {
+	if !match.AcceptsPredictions() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Predictions are closed for this match", "status": match.Status})
+		return false
+	}
+
+	deadline, err := predictionDeadline(match)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to determine prediction deadline"})
+		return false
+	}
+
+	if !time.Now().UTC().Before(deadline) {
+		c.JSON(http.StatusLocked, gin.H{
+			"error":    fmt.Sprintf("Predictions for this match locked at %s", deadline.UTC().Format(time.RFC3339)),
+			"deadline": deadline.UTC(),
+		})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"ball-knowledge/database"
+	"ball-knowledge/database/databasetest"
+	"ball-knowledge/models"
+)
+
+func createGameweekMatch(t *testing.T, status string, date time.Time) models.Match {
+	t.Helper()
+	match := models.Match{
+		HomeTeam: "Home " + status,
+		AwayTeam: "Away " + status,
+		Date:     date,
+		League:   "Premier League",
+		Season:   "2024",
+		MatchDay: 10,
+		Status:   status,
+	}
+	if err := database.DB.Create(&match).Error; err != nil {
+		t.Fatal(err)
+	}
+	return match
+}
+
+func TestGameweekDeadlineIgnoresPostponedAndCancelledMatches(t *testing.T) {
+	databasetest.Open(t)
+	t.Setenv("PREDICTION_GAMEWEEK_DEADLINE", "true")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	// Both keep the date they were originally due to be played on
+	createGameweekMatch(t, models.MatchStatusPostponed, now.Add(-48*time.Hour))
+	createGameweekMatch(t, models.MatchStatusCancelled, now.Add(-24*time.Hour))
+	first := createGameweekMatch(t, models.MatchStatusScheduled, now.Add(24*time.Hour))
+	later := createGameweekMatch(t, models.MatchStatusScheduled, now.Add(48*time.Hour))
+
+	deadline, err := predictionDeadline(later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deadline.Equal(first.Date) {
+		t.Errorf("deadline is %s, want the first scheduled kickoff %s", deadline, first.Date)
+	}
+}
+
+func TestGameweekDeadlineCountsStartedMatches(t *testing.T) {
+	databasetest.Open(t)
+	t.Setenv("PREDICTION_GAMEWEEK_DEADLINE", "true")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	live := createGameweekMatch(t, models.MatchStatusLive, now.Add(-time.Hour))
+	later := createGameweekMatch(t, models.MatchStatusScheduled, now.Add(48*time.Hour))
+
+	deadline, err := predictionDeadline(later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deadline.Equal(live.Date) {
+		t.Errorf("deadline is %s, want the live match's kickoff %s", deadline, live.Date)
+	}
+}
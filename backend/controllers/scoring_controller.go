@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"ball-knowledge/audit"
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+	"ball-knowledge/scoring"
+	"ball-knowledge/settlement"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AssignRuleSetRequest struct {
+	League  string `json:"league" binding:"required"`
+	Season  string `json:"season"` // Empty applies to every season of the league
+	RuleSet string `json:"rule_set" binding:"required"`
+	Version int    `json:"version" binding:"min=0"` // 0 follows the latest version
+}
+
+// GetScoringRules returns the rule set that scores a competition
+func GetScoringRules(c *gin.Context) {
+	league := c.Query("league")
+	if league == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "league is required"})
+		return
+	}
+	season := c.Query("season")
+
+	ruleSet, _, err := scoring.ForCompetition(database.DB, league, season)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load scoring rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"league":   league,
+		"season":   season,
+		"rule_set": ruleSet,
+	})
+}
+
+// GetRuleSets lists every stored rule set version (admin function)
+func GetRuleSets(c *gin.Context) {
+	var stored []models.ScoringRuleSet
+	if err := database.DB.Order("name ASC, version DESC").Find(&stored).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve rule sets"})
+		return
+	}
+
+	ruleSets := make([]gin.H, 0, len(stored))
+	for _, s := range stored {
+		ruleSet, err := scoring.Decode(s)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ruleSets = append(ruleSets, gin.H{
+			"id":         s.ID,
+			"created_at": s.CreatedAt,
+			"rule_set":   ruleSet,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  ruleSets,
+		"count": len(ruleSets),
+	})
+}
+
+// CreateRuleSet saves a rule set as the next version of its name and re-scores the
+// competitions that follow its latest version (admin function)
+func CreateRuleSet(c *gin.Context) {
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var ruleSet scoring.RuleSet
+	if err := c.ShouldBindJSON(&ruleSet); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := ruleSet.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var stored models.ScoringRuleSet
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if stored, err = scoring.SaveNewVersion(tx, ruleSet); err != nil {
+			return err
+		}
+		return audit.Record(tx, &actorID, audit.ActionRuleSetCreate, audit.EntityRuleSet, stored.ID.String(), gin.H{
+			"name":    stored.Name,
+			"version": stored.Version,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rule set"})
+		return
+	}
+
+	summary, err := rescoreFollowers(ruleSet.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rule set saved but re-scoring failed"})
+		return
+	}
+
+	ruleSet.Version = stored.Version
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Rule set saved",
+		"id":       stored.ID,
+		"rule_set": ruleSet,
+		"summary":  summary,
+	})
+}
+
+// rescoreFollowers re-scores the competitions that follow the latest version of a rule set
+func rescoreFollowers(name string) (settlement.Summary, error) {
+	var total settlement.Summary
+
+	assignments, unassigned, err := scoring.FollowingLatest(database.DB, name)
+	if err != nil {
+		return total, err
+	}
+
+	scopes := make([]settlement.Scope, 0, len(assignments))
+	for _, a := range assignments {
+		scopes = append(scopes, settlement.Scope{League: a.League, Season: a.Season})
+	}
+	if unassigned {
+		// Competitions without an assignment can't be listed, so re-score everything
+		scopes = []settlement.Scope{{}}
+	}
+
+	for _, scope := range scopes {
+		summary, err := settlement.Rescore(scope)
+		if err != nil {
+			return total, err
+		}
+		total.Matches += summary.Matches
+		total.Predictions += summary.Predictions
+		total.Updated += summary.Updated
+	}
+	return total, nil
+}
+
+// GetCompetitionScoring lists which rule set each competition uses (admin function)
+func GetCompetitionScoring(c *gin.Context) {
+	var assignments []models.CompetitionScoring
+	if err := database.DB.Order("league ASC, season ASC").Find(&assignments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve competition scoring"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  assignments,
+		"count": len(assignments),
+	})
+}
+
+// AssignRuleSet selects a competition's rule set and re-scores its matches (admin function)
+func AssignRuleSet(c *gin.Context) {
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var req AssignRuleSetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var assignment models.CompetitionScoring
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if assignment, err = scoring.Assign(tx, req.League, req.Season, req.RuleSet, req.Version); err != nil {
+			return err
+		}
+		return audit.Record(tx, &actorID, audit.ActionRuleSetAssign, audit.EntityCompetition, assignment.ID.String(), gin.H{
+			"league":   assignment.League,
+			"season":   assignment.Season,
+			"rule_set": assignment.RuleSetName,
+			"version":  assignment.RuleSetVersion,
+		})
+	})
+	if errors.Is(err, scoring.ErrRuleSetNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule set not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign rule set"})
+		return
+	}
+
+	summary, err := settlement.Rescore(settlement.Scope{League: req.League, Season: req.Season})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rule set assigned but re-scoring failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Rule set assigned",
+		"assignment": assignment,
+		"summary":    summary,
+	})
+}
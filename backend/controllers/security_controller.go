@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+	"ball-knowledge/security"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSecurityEvents returns the most recent security events, optionally filtered by
+// type, user, IP address or a start time (admin function)
+func GetSecurityEvents(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+
+	query := database.DB.Order("created_at DESC").Limit(limit)
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip_address = ?", ip)
+	}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC3339 time"})
+			return
+		}
+		query = query.Where("created_at >= ?", t.UTC())
+	}
+
+	var events []models.SecurityEvent
+	if err := query.Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve security events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  events,
+		"count": len(events),
+	})
+}
+
+// ClearUserLockout lets a locked out user try to log in again right away (admin function)
+func ClearUserLockout(c *gin.Context) {
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := security.ClearLockout(security.AccountKey(user.ID.String())); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear lockout"})
+		return
+	}
+	security.RecordEvent(security.EventLockoutCleared, &user.ID, c.ClientIP(), gin.H{"cleared_by": actorID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lockout cleared"})
+}
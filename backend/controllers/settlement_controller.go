@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"ball-knowledge/audit"
+	"ball-knowledge/database"
+	"ball-knowledge/leaderboard"
+	"ball-knowledge/settlement"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RescoreRequest struct {
+	Season   string `json:"season"`
+	GameWeek int    `json:"gameweek" binding:"min=0"`
+}
+
+// RescorePredictions recomputes prediction points for a season, a gameweek or everything (admin function)
+func RescorePredictions(c *gin.Context) {
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var req RescoreRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.GameWeek != 0 && req.Season == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "season is required when re-scoring a gameweek"})
+		return
+	}
+
+	summary, err := settlement.Rescore(settlement.Scope{Season: req.Season, MatchDay: req.GameWeek})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to re-score predictions"})
+		return
+	}
+
+	// Matches are re-scored one transaction each, so the entry is written once they all are
+	if err := audit.Record(database.DB, &actorID, audit.ActionRescore, audit.EntityLeaderboard, "", gin.H{
+		"season":   req.Season,
+		"gameweek": req.GameWeek,
+		"summary":  summary,
+	}); err != nil {
+		log.Printf("⚠️  Failed to audit re-score: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Predictions re-scored successfully",
+		"summary": summary,
+	})
+}
+
+// RebuildStandings recomputes the leaderboard standings from the predictions table (admin function)
+func RebuildStandings(c *gin.Context) {
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	rebuilt, err := leaderboard.RebuildStandings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rebuild standings"})
+		return
+	}
+
+	if err := audit.Record(database.DB, &actorID, audit.ActionRebuild, audit.EntityLeaderboard, "", gin.H{
+		"users": rebuilt,
+	}); err != nil {
+		log.Printf("⚠️  Failed to audit standings rebuild: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Standings rebuilt",
+		"users":   rebuilt,
+	})
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"ball-knowledge/database"
+	"ball-knowledge/fixtures"
+	"ball-knowledge/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSyncRuns returns the most recent fixture sync runs (admin function)
+func GetSyncRuns(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+
+	var runs []models.SyncRun
+	if err := database.DB.Order("started_at DESC").Limit(limit).Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sync runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  runs,
+		"count": len(runs),
+	})
+}
+
+// TriggerSync runs a fixture sync immediately (admin function)
+func TriggerSync(c *gin.Context) {
+	// Don't tie the sync to the request: a client disconnect shouldn't abort it
+	run, err := fixtures.Run(context.Background(), fixtures.TriggerManual)
+	if errors.Is(err, fixtures.ErrSyncInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if run == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": "Fixture sync failed",
+			"run":   run,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Fixture sync completed",
+		"run":     run,
+	})
+}
+
+// GetMatchChanges returns the match change log, optionally filtered by match or sync run (admin function)
+func GetMatchChanges(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+
+	query := database.DB.Order("created_at DESC").Limit(limit)
+	if matchID := c.Query("match_id"); matchID != "" {
+		query = query.Where("match_id = ?", matchID)
+	}
+	if runID := c.Query("sync_run_id"); runID != "" {
+		query = query.Where("sync_run_id = ?", runID)
+	}
+
+	var changes []models.MatchChange
+	if err := query.Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve match changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  changes,
+		"count": len(changes),
+	})
+}
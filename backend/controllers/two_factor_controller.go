@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"ball-knowledge/auth"
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+	"ball-knowledge/security"
+	"ball-knowledge/twofactor"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+// GetTwoFactorStatus reports whether the current user has two-factor authentication
+// and how many recovery codes they have left
+func GetTwoFactorStatus(c *gin.Context) {
+	user, ok := loadAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	remaining, err := twofactor.RemainingRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve two-factor status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  user.TOTPEnabled,
+		"required":                 auth.RequiresTwoFactor(user.Role),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// EnrollTwoFactor starts two-factor enrolment, returning a new TOTP secret and its
+// otpauth URI for the user's authenticator app. Enrolling again replaces a pending secret.
+func EnrollTwoFactor(c *gin.Context) {
+	user, ok := loadAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	secret, uri, err := twofactor.BeginEnrollment(user)
+	if errors.Is(err, twofactor.ErrAlreadyEnabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor enrolment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Add this secret to your authenticator app, then confirm with a code from it",
+		"secret":      secret,
+		"otpauth_uri": uri,
+	})
+}
+
+// ConfirmTwoFactor enables two-factor authentication with a code from the newly enrolled
+// app. It returns the recovery codes, shown only this once, and tokens for a two-factor login.
+func ConfirmTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	// Wrong codes are throttled like those entered at login, so a stolen session can't
+	// be used to guess its way through enrolment
+	keys := security.NewLoginKeys(user.ID.String(), "", c.ClientIP())
+	if !allowTwoFactorAttempt(c, user, keys) {
+		return
+	}
+
+	codes, err := twofactor.ConfirmEnrollment(user, req.Code)
+	switch {
+	case errors.Is(err, twofactor.ErrAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	case errors.Is(err, twofactor.ErrNotEnrolled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor enrolment first"})
+		return
+	case errors.Is(err, twofactor.ErrInvalidCode):
+		twoFactorCodeFailed(c, user, keys)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	security.RecordEvent(security.EventTwoFactorEnabled, &user.ID, c.ClientIP(), nil)
+	log.Printf("🔐 Two-factor authentication enabled for user %s", user.ID)
+
+	// The code proves the second factor, so this login can be upgraded without logging in again
+	user.TOTPEnabled = true
+	response, ok := issueTokens(c, user, true)
+	if !ok {
+		return
+	}
+
+	response["message"] = "Two-factor authentication enabled. Store your recovery codes somewhere safe."
+	response["recovery_codes"] = codes
+	c.JSON(http.StatusOK, response)
+}
+
+// DisableTwoFactor turns off two-factor authentication for the current user, who must
+// confirm with their password and a code. Roles that require it can't turn it off.
+func DisableTwoFactor(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if auth.RequiresTwoFactor(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+	if !verifyTwoFactorCode(c, user, req.Code, security.NewLoginKeys(user.ID.String(), "", c.ClientIP())) {
+		return
+	}
+
+	if err := twofactor.Disable(database.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	security.RecordEvent(security.EventTwoFactorDisabled, &user.ID, c.ClientIP(), nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes, after confirming a code
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := loadAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if !verifyTwoFactorCode(c, user, req.Code, security.NewLoginKeys(user.ID.String(), "", c.ClientIP())) {
+		return
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = twofactor.RegenerateRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "New recovery codes generated; the old ones no longer work",
+		"recovery_codes": codes,
+	})
+}
+
+// ResetUserTwoFactor turns off two-factor authentication for a user who lost their
+// device and recovery codes (admin function). They must enrol again if their role requires it.
+func ResetUserTwoFactor(c *gin.Context) {
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := twofactor.Disable(database.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+	security.RecordEvent(security.EventTwoFactorDisabled, &user.ID, c.ClientIP(), gin.H{"disabled_by": actorID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+// verifyTwoFactorCode checks a TOTP or recovery code for a user. Wrong codes count as
+// failed logins against keys, so guessing codes backs off and locks out like guessing
+// passwords. It responds with an error itself on failure.
+func verifyTwoFactorCode(c *gin.Context, user models.User, code string, keys security.LoginKeys) bool {
+	if !allowTwoFactorAttempt(c, user, keys) {
+		return false
+	}
+
+	usedRecoveryCode, err := twofactor.Verify(user, code)
+	if errors.Is(err, twofactor.ErrInvalidCode) || errors.Is(err, twofactor.ErrNotEnrolled) {
+		twoFactorCodeFailed(c, user, keys)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return false
+	}
+
+	if usedRecoveryCode {
+		remaining, _ := twofactor.RemainingRecoveryCodes(user.ID)
+		security.RecordEvent(security.EventRecoveryCodeUsed, &user.ID, c.ClientIP(), gin.H{"remaining": remaining})
+	}
+	return true
+}
+
+// allowTwoFactorAttempt reports whether a two-factor code may be tried against keys now.
+// It responds with an error itself when not.
+func allowTwoFactorAttempt(c *gin.Context, user models.User, keys security.LoginKeys) bool {
+	wait, err := security.CheckLogin(keys)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return false
+	}
+	if wait > 0 {
+		security.RecordEvent(security.EventLoginThrottled, &user.ID, c.ClientIP(), gin.H{"step": "2fa"})
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+		return false
+	}
+	return true
+}
+
+// twoFactorCodeFailed counts a wrong two-factor code as a failed login against keys
+func twoFactorCodeFailed(c *gin.Context, user models.User, keys security.LoginKeys) {
+	locked, err := security.LoginFailed(keys)
+	if err != nil {
+		log.Printf("⚠️  Failed to record failed two-factor code: %v", err)
+	}
+	security.RecordEvent(security.EventTwoFactorFailed, &user.ID, c.ClientIP(), nil)
+	if locked {
+		security.RecordEvent(security.EventAccountLocked, &user.ID, c.ClientIP(), gin.H{"step": "2fa"})
+	}
+}
+
+// loadAuthenticatedUser loads the user making the request. It responds with an error itself on failure.
+func loadAuthenticatedUser(c *gin.Context) (models.User, bool) {
+	userID, ok := authenticatedUserID(c)
+	if !ok {
+		return models.User{}, false
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return models.User{}, false
+	}
+	return user, true
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"ball-knowledge/audit"
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var errLastAdmin = errors.New("cannot remove the last admin")
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// AdminUser is a user as listed to admins
+type AdminUser struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+}
+
+// GetUsers lists users and their roles, optionally only those with ?role= (admin function)
+func GetUsers(c *gin.Context) {
+	query := database.DB.Model(&models.User{}).Order("username ASC")
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	users := []AdminUser{}
+	if err := query.Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  users,
+		"count": len(users),
+	})
+}
+
+// UpdateUserRole changes a user's role (admin function). The change applies to
+// tokens issued from then on, i.e. at the user's next login or token refresh.
+func UpdateUserRole(c *gin.Context) {
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be user, moderator or admin"})
+		return
+	}
+
+	actorID, ok := authenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	oldRole := user.Role
+	if oldRole == req.Role {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "No changes",
+			"user":    AdminUser{ID: user.ID, Username: user.Username, Email: user.Email, Role: user.Role},
+		})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		demoting := oldRole == models.RoleAdmin && req.Role != models.RoleAdmin
+		query := tx.Model(&user)
+		if demoting {
+			// Never leave the system without an admin. Counting in the same statement as the
+			// update keeps two admins demoting each other at once from both succeeding.
+			query = query.Where("role = ? AND (SELECT COUNT(*) FROM users WHERE role = ?) > 1",
+				models.RoleAdmin, models.RoleAdmin)
+		}
+		result := query.Update("role", req.Role)
+		if result.Error != nil {
+			return result.Error
+		}
+		if demoting && result.RowsAffected == 0 {
+			return errLastAdmin
+		}
+
+		return audit.Record(tx, &actorID, audit.ActionUserRole, audit.EntityUser, user.ID.String(), gin.H{
+			"username": user.Username,
+			"old_role": oldRole,
+			"new_role": req.Role,
+		})
+	})
+	if errors.Is(err, errLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot remove the last admin"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role updated",
+		"user": AdminUser{
+			ID:       user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+			Role:     user.Role,
+		},
+	})
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"log"
+	"strings"
+
+	"ball-knowledge/config"
+	"ball-knowledge/models"
+)
+
+// IsBootstrapAdmin reports whether email is BOOTSTRAP_ADMIN_EMAIL and there is no admin yet,
+// in which case that user is made the first admin once they've verified the address
+func IsBootstrapAdmin(email string) bool {
+	bootstrap := config.String("BOOTSTRAP_ADMIN_EMAIL", "")
+	if bootstrap == "" || !strings.EqualFold(bootstrap, strings.TrimSpace(email)) {
+		return false
+	}
+
+	var admins int64
+	if err := DB.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&admins).Error; err != nil {
+		log.Printf("⚠️  Failed to check for existing admins: %v", err)
+		return false
+	}
+	return admins == 0
+}
+
+// PromoteBootstrapAdmin makes the BOOTSTRAP_ADMIN_EMAIL user an admin when there is no admin
+// yet. Only a verified account is promoted, or anyone could register with the address and
+// become admin without owning it.
+func PromoteBootstrapAdmin() error {
+	email := config.String("BOOTSTRAP_ADMIN_EMAIL", "")
+	if !IsBootstrapAdmin(email) {
+		return nil
+	}
+
+	result := DB.Model(&models.User{}).
+		Where("LOWER(email) = LOWER(?) AND email_verified = ?", email, true).
+		Update("role", models.RoleAdmin)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("✅ Promoted bootstrap admin %s", email)
+	}
+	return nil
+}
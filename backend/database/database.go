@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"ball-knowledge/models"
+	"ball-knowledge/scoring"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -58,18 +59,49 @@ func ConnectDatabase() error {
 		return fmt.Errorf("failed to migrate database: %v", err)
 	}
 
+	// Store built-in and file-defined scoring rule sets
+	if err := scoring.Seed(database, os.Getenv("SCORING_RULES_DIR")); err != nil {
+		return fmt.Errorf("failed to seed scoring rule sets: %v", err)
+	}
+
 	DB = database
 	log.Println("✅ Database connected and migrated successfully")
 	return nil
 }
 
-// autoMigrate runs database migrations
+// autoMigrate runs database migrations and backfills newly added columns
 func autoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := migrateMatchDateColumn(db); err != nil {
+		return err
+	}
+
+	backfills := pendingBackfills(db)
+
+	if err := db.AutoMigrate(
 		&models.User{},
 		&models.Match{},
 		&models.Prediction{},
-	)
+		&models.SyncRun{},
+		&models.MatchChange{},
+		&models.ScoringRuleSet{},
+		&models.CompetitionScoring{},
+		&models.MiniLeague{},
+		&models.MiniLeagueMember{},
+		&models.RankSnapshot{},
+		&models.Standing{},
+		&models.AuditLog{},
+		&models.RefreshToken{},
+		&models.PasswordResetToken{},
+		&models.LoginThrottle{},
+		&models.SecurityEvent{},
+		&models.RecoveryCode{},
+		&models.UserIdentity{},
+		&models.OIDCLoginState{},
+	); err != nil {
+		return err
+	}
+
+	return runBackfills(db, backfills)
 }
 
 // GetDB returns the database instance
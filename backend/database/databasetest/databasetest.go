@@ -0,0 +1,33 @@
+// Package databasetest connects tests to a fresh, migrated in-memory database.
+package databasetest
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"ball-knowledge/database"
+)
+
+var databases atomic.Int64
+
+// Open points database.DB at a new in-memory SQLite database, migrated and seeded like
+// a real one, and closes it when the test ends. Tests using it must not run in parallel.
+func Open(t testing.TB) {
+	t.Helper()
+
+	// Every connection to a named shared-cache database sees the same data, and the
+	// database lives until its last connection closes
+	t.Setenv("DB_PATH", fmt.Sprintf("file:databasetest%d?mode=memory&cache=shared", databases.Add(1)))
+	t.Setenv("SCORING_RULES_DIR", "")
+
+	if err := database.ConnectDatabase(); err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := database.CloseDatabase(); err != nil {
+			t.Errorf("failed to close test database: %v", err)
+		}
+		database.DB = nil
+	})
+}
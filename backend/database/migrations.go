@@ -0,0 +1,188 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"ball-knowledge/models"
+	"ball-knowledge/scoring"
+
+	"gorm.io/gorm"
+)
+
+// columnBackfill fills in a column for existing rows the first time it is added
+type columnBackfill struct {
+	model    interface{}
+	field    string
+	backfill func(tx *gorm.DB) error
+}
+
+var columnBackfills = []columnBackfill{
+	{&models.Match{}, "Status", backfillMatchStatus},
+	{&models.User{}, "CreatedAt", backfillUserCreatedAt},
+	{&models.Prediction{}, "ExactScore", backfillPredictionAccuracy},
+	{&models.User{}, "EmailVerified", backfillUserEmailVerified},
+}
+
+// pendingBackfills returns the backfills whose table exists but whose column doesn't yet.
+// It must be called before AutoMigrate adds the columns.
+func pendingBackfills(db *gorm.DB) []columnBackfill {
+	var pending []columnBackfill
+	for _, b := range columnBackfills {
+		if db.Migrator().HasTable(b.model) && !db.Migrator().HasColumn(b.model, b.field) {
+			pending = append(pending, b)
+		}
+	}
+	return pending
+}
+
+// runBackfills applies each backfill in its own transaction
+func runBackfills(db *gorm.DB, backfills []columnBackfill) error {
+	for _, b := range backfills {
+		if err := db.Transaction(b.backfill); err != nil {
+			return fmt.Errorf("backfill of %s failed: %v", b.field, err)
+		}
+		log.Printf("✅ Backfilled %T.%s for existing rows", b.model, b.field)
+	}
+	return nil
+}
+
+// backfillMatchStatus derives a status for matches stored before statuses existed.
+// Matches with a real score are finished. Unplayed matches used to be stored with a
+// "0:0" placeholder result, which is cleared; the next sync restores genuine 0:0 draws.
+func backfillMatchStatus(tx *gorm.DB) error {
+	if err := tx.Model(&models.Match{}).
+		Where("result <> '' AND result <> '0:0'").
+		Update("status", models.MatchStatusFinished).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Match{}).
+		Where("status = ? AND result = '0:0'", models.MatchStatusScheduled).
+		Update("result", "").Error
+}
+
+// backfillUserCreatedAt stamps users registered before registration times were
+// recorded with the migration time, so they tie on earliest registration
+func backfillUserCreatedAt(tx *gorm.DB) error {
+	return tx.Model(&models.User{}).
+		Where("created_at IS NULL").
+		Update("created_at", time.Now().UTC()).Error
+}
+
+// backfillUserEmailVerified marks users registered before email verification existed
+// as verified, so they aren't locked out of predicting or hidden from leaderboards
+func backfillUserEmailVerified(tx *gorm.DB) error {
+	return tx.Model(&models.User{}).
+		Where("1 = 1").
+		Update("email_verified", true).Error
+}
+
+// backfillPredictionAccuracy sets the exact score and correct outcome flags on
+// predictions for matches that finished before the flags existed
+func backfillPredictionAccuracy(tx *gorm.DB) error {
+	var matches []models.Match
+	if err := tx.Where("status = ?", models.MatchStatusFinished).Find(&matches).Error; err != nil {
+		return err
+	}
+
+	for _, match := range matches {
+		var predictions []models.Prediction
+		if err := tx.Where("match_id = ?", match.ID).Find(&predictions).Error; err != nil {
+			return err
+		}
+
+		for _, prediction := range predictions {
+			exactScore, correctOutcome := scoring.Accuracy(match, prediction.PredictedScoreHome, prediction.PredictedScoreAway)
+			if !exactScore && !correctOutcome {
+				continue
+			}
+			if err := tx.Model(&models.Prediction{}).
+				Where("id = ?", prediction.ID).
+				Updates(map[string]interface{}{
+					"exact_score":     exactScore,
+					"correct_outcome": correctOutcome,
+				}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// legacyDateLayouts are the formats match dates were stored in while they were strings
+var legacyDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// migrateMatchDateColumn converts matches.date from RFC3339 text to a UTC datetime.
+// It must run before AutoMigrate and does nothing once the column is a datetime.
+func migrateMatchDateColumn(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&models.Match{}) {
+		return nil
+	}
+
+	columnTypes, err := db.Migrator().ColumnTypes(&models.Match{})
+	if err != nil {
+		return err
+	}
+	for _, column := range columnTypes {
+		if column.Name() == "date" && strings.EqualFold(column.DatabaseTypeName(), "datetime") {
+			return nil
+		}
+	}
+
+	var rows []struct {
+		ID   string
+		Date string
+	}
+	if err := db.Raw("SELECT id, date FROM matches").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	// Parse everything up front so a bad value leaves the table untouched
+	dates := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		date, err := parseLegacyDate(row.Date)
+		if err != nil {
+			return fmt.Errorf("match %s has unparseable date %q", row.ID, row.Date)
+		}
+		dates[row.ID] = date
+	}
+
+	if err := db.Migrator().AlterColumn(&models.Match{}, "Date"); err != nil {
+		return fmt.Errorf("failed to change matches.date to datetime: %v", err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for id, date := range dates {
+			if err := tx.Exec("UPDATE matches SET date = ? WHERE id = ?", date, id).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rewrite match dates: %v", err)
+	}
+
+	log.Printf("✅ Converted %d match dates to UTC timestamps", len(dates))
+	return nil
+}
+
+func parseLegacyDate(value string) (time.Time, error) {
+	var err error
+	for _, layout := range legacyDateLayouts {
+		var date time.Time
+		if date, err = time.Parse(layout, value); err == nil {
+			return date.UTC(), nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,184 @@
+package fixtures
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ball-knowledge/models"
+)
+
+const defaultAPISportsBaseURL = "https://v3.football.api-sports.io"
+
+// APISportsProvider fetches fixtures from the api-sports.io v3 football API
+type APISportsProvider struct {
+	BaseURL  string
+	APIKey   string
+	LeagueID string
+	Season   string
+	Client   *http.Client
+}
+
+// apiSportsResponse mirrors the parts of the /fixtures response we use
+type apiSportsResponse struct {
+	Errors   json.RawMessage `json:"errors"`
+	Response []struct {
+		Fixture struct {
+			ID     int    `json:"id"`
+			Date   string `json:"date"`
+			Status struct {
+				Short string `json:"short"`
+			} `json:"status"`
+		} `json:"fixture"`
+		League struct {
+			Name  string `json:"name"`
+			Round string `json:"round"`
+		} `json:"league"`
+		Teams struct {
+			Home struct {
+				Name string `json:"name"`
+			} `json:"home"`
+			Away struct {
+				Name string `json:"name"`
+			} `json:"away"`
+		} `json:"teams"`
+		Score struct {
+			Fulltime struct {
+				Home *int `json:"home"`
+				Away *int `json:"away"`
+			} `json:"fulltime"`
+		} `json:"score"`
+	} `json:"response"`
+}
+
+func (p *APISportsProvider) Name() string {
+	return ProviderAPISports
+}
+
+// FetchFixtures fetches the league's fixtures for the configured season
+func (p *APISportsProvider) FetchFixtures(ctx context.Context) ([]Fixture, error) {
+	if p.APIKey == "" {
+		return nil, fmt.Errorf("API_FOOTBALL_KEY environment variable not set")
+	}
+
+	// Build API URL
+	url := fmt.Sprintf("%s/fixtures?league=%s&season=%s", p.BaseURL, p.LeagueID, p.Season)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	req.Header.Add("x-apisports-key", p.APIKey)
+
+	body, err := doRequest(p.Client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse JSON response
+	var apiResponse apiSportsResponse
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %v", err)
+	}
+
+	// api-sports reports problems such as bad keys with a 200 and a non-empty "errors" field
+	if hasAPISportsErrors(apiResponse.Errors) {
+		return nil, fmt.Errorf("API returned errors: %s", apiResponse.Errors)
+	}
+
+	var fixtures []Fixture
+
+	for _, item := range apiResponse.Response {
+		// Parse date
+		date, err := time.Parse(time.RFC3339, item.Fixture.Date)
+		if err != nil {
+			log.Printf("Error parsing date %s: %v", item.Fixture.Date, err)
+			continue
+		}
+
+		// Extract match day from round string
+		var matchDay int
+		fmt.Sscanf(item.League.Round, "Regular Season - %d", &matchDay)
+		if matchDay == 0 {
+			// Try other formats
+			fmt.Sscanf(item.League.Round, "Matchday %d", &matchDay)
+		}
+
+		league := item.League.Name
+		if league == "" {
+			league = "Premier League"
+		}
+
+		fixtures = append(fixtures, Fixture{
+			ExternalID: strconv.Itoa(item.Fixture.ID),
+			HomeTeam:   item.Teams.Home.Name,
+			AwayTeam:   item.Teams.Away.Name,
+			Kickoff:    date,
+			League:     league,
+			Season:     p.Season,
+			MatchDay:   matchDay,
+			HomeScore:  item.Score.Fulltime.Home,
+			AwayScore:  item.Score.Fulltime.Away,
+			Status:     apiSportsStatus(item.Fixture.Status.Short),
+		})
+	}
+
+	return fixtures, nil
+}
+
+// apiSportsStatus maps api-sports short status codes to match statuses. Suspended and
+// interrupted matches stay live: they have kicked off, so predictions must stay closed
+// until they finish or are postponed. Matches awarded to one side (AWD, WO) weren't
+// played to a result, so they are cancelled and their predictions voided.
+func apiSportsStatus(short string) string {
+	switch short {
+	case "1H", "HT", "2H", "ET", "BT", "P", "SUSP", "INT", "LIVE":
+		return models.MatchStatusLive
+	case "FT", "AET", "PEN":
+		return models.MatchStatusFinished
+	case "PST":
+		return models.MatchStatusPostponed
+	case "CANC", "ABD", "AWD", "WO":
+		return models.MatchStatusCancelled
+	default: // "TBD", "NS"
+		return models.MatchStatusScheduled
+	}
+}
+
+// hasAPISportsErrors reports whether the "errors" field holds anything other than an empty list or object
+func hasAPISportsErrors(raw json.RawMessage) bool {
+	switch string(raw) {
+	case "", "null", "[]", "{}":
+		return false
+	}
+	return true
+}
+
+// doRequest performs an HTTP request and returns the body of a 200 response
+func doRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making API request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %v", err)
+	}
+	return body, nil
+}
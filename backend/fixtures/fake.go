@@ -0,0 +1,46 @@
+package fixtures
+
+import (
+	"log"
+
+	"ball-knowledge/config"
+	"ball-knowledge/fixtures/fakeapi"
+)
+
+// FakeProvider is the api-sports provider pointed at an in-process fake server
+type FakeProvider struct {
+	APISportsProvider
+	server *fakeapi.Server
+}
+
+// NewFakeProvider starts a fake api-sports server and returns a provider that talks to it.
+// Call Close to shut the server down.
+func NewFakeProvider(payloadPath, season string) (*FakeProvider, error) {
+	server, err := fakeapi.NewServer(payloadPath)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("🧪 Serving recorded api-sports fixtures from %s", server.URL)
+
+	return &FakeProvider{
+		APISportsProvider: APISportsProvider{
+			BaseURL:  server.URL,
+			APIKey:   "fake",
+			LeagueID: config.String("LEAGUE_ID", "39"),
+			Season:   season,
+			Client:   server.Client(),
+		},
+		server: server,
+	}, nil
+}
+
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+// Close shuts down the fake server
+func (p *FakeProvider) Close() error {
+	p.server.Close()
+	return nil
+}
@@ -0,0 +1,70 @@
+// Package fakeapi serves recorded api-sports payloads from an in-process HTTP
+// server, so the fixture sync can run offline without an API_FOOTBALL_KEY.
+package fakeapi
+
+import (
+	"embed"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+)
+
+//go:embed recordings/*.json
+var recordings embed.FS
+
+// Server is a fake api-sports server backed by recorded /fixtures responses
+type Server struct {
+	*httptest.Server
+
+	payload []byte // when set, served for every league and season
+}
+
+// NewServer starts a fake api-sports server.
+//
+// If payloadPath is empty the embedded recordings are used, looked up by
+// the league and season query parameters. Otherwise the file at payloadPath
+// is served for every /fixtures request.
+func NewServer(payloadPath string) (*Server, error) {
+	s := &Server{}
+
+	if payloadPath != "" {
+		payload, err := os.ReadFile(payloadPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading fake payload: %v", err)
+		}
+		s.payload = payload
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fixtures", s.handleFixtures)
+	s.Server = httptest.NewServer(mux)
+
+	return s, nil
+}
+
+func (s *Server) handleFixtures(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Like the real API, a missing key is reported in the body with a 200 status
+	if r.Header.Get("x-apisports-key") == "" {
+		fmt.Fprint(w, `{"get":"fixtures","parameters":[],"errors":{"token":"Missing application key."},"results":0,"response":[]}`)
+		return
+	}
+
+	if s.payload != nil {
+		w.Write(s.payload)
+		return
+	}
+
+	league := r.URL.Query().Get("league")
+	season := r.URL.Query().Get("season")
+
+	payload, err := recordings.ReadFile(fmt.Sprintf("recordings/fixtures_%s_%s.json", league, season))
+	if err != nil {
+		fmt.Fprint(w, `{"get":"fixtures","errors":[],"results":0,"response":[]}`)
+		return
+	}
+
+	w.Write(payload)
+}
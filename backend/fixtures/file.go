@@ -0,0 +1,143 @@
+package fixtures
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileProvider reads fixtures from a local JSON or CSV file.
+//
+// JSON files hold an array of objects using the Fixture field names
+// (external_id, home_team, away_team, kickoff, league, season, match_day,
+// home_score, away_score, status). CSV files use the same names as a header row;
+// kickoff is RFC3339 and empty scores mean the match hasn't been played.
+// Fixtures without a league or season get the provider defaults, and
+// fixtures without a status are finished when they have a score.
+type FileProvider struct {
+	Path          string
+	DefaultLeague string
+	DefaultSeason string
+}
+
+func (p *FileProvider) Name() string {
+	return ProviderFile
+}
+
+// FetchFixtures reads and parses the fixture file
+func (p *FileProvider) FetchFixtures(ctx context.Context) ([]Fixture, error) {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fixture file: %v", err)
+	}
+
+	var fixtures []Fixture
+	switch strings.ToLower(filepath.Ext(p.Path)) {
+	case ".json":
+		if err := json.Unmarshal(data, &fixtures); err != nil {
+			return nil, fmt.Errorf("error parsing JSON: %v", err)
+		}
+	case ".csv":
+		if fixtures, err = parseFixtureCSV(string(data)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported fixture file type %q (use .json or .csv)", filepath.Ext(p.Path))
+	}
+
+	for i := range fixtures {
+		if fixtures[i].League == "" {
+			fixtures[i].League = p.DefaultLeague
+		}
+		if fixtures[i].Season == "" {
+			fixtures[i].Season = p.DefaultSeason
+		}
+		if fixtures[i].Status == "" {
+			fixtures[i].Status = inferStatus(fixtures[i])
+		}
+	}
+
+	return fixtures, nil
+}
+
+// parseFixtureCSV parses CSV fixture data with a header row
+func parseFixtureCSV(data string) ([]Fixture, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"home_team", "away_team", "kickoff"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV is missing required column %q", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var fixtures []Fixture
+	for line, record := range records[1:] {
+		kickoff, err := time.Parse(time.RFC3339, field(record, "kickoff"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid kickoff: %v", line+2, err)
+		}
+
+		fixture := Fixture{
+			ExternalID: field(record, "external_id"),
+			HomeTeam:   field(record, "home_team"),
+			AwayTeam:   field(record, "away_team"),
+			Kickoff:    kickoff,
+			League:     field(record, "league"),
+			Season:     field(record, "season"),
+			Status:     strings.ToLower(field(record, "status")),
+		}
+
+		if value := field(record, "match_day"); value != "" {
+			if fixture.MatchDay, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("line %d: invalid match_day: %v", line+2, err)
+			}
+		}
+		if fixture.HomeScore, err = parseOptionalInt(field(record, "home_score")); err != nil {
+			return nil, fmt.Errorf("line %d: invalid home_score: %v", line+2, err)
+		}
+		if fixture.AwayScore, err = parseOptionalInt(field(record, "away_score")); err != nil {
+			return nil, fmt.Errorf("line %d: invalid away_score: %v", line+2, err)
+		}
+
+		fixtures = append(fixtures, fixture)
+	}
+
+	return fixtures, nil
+}
+
+func parseOptionalInt(value string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
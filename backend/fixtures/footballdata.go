@@ -0,0 +1,126 @@
+package fixtures
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ball-knowledge/models"
+)
+
+const defaultFootballDataBaseURL = "https://api.football-data.org/v4"
+
+// FootballDataProvider fetches fixtures from the football-data.org v4 API
+type FootballDataProvider struct {
+	BaseURL     string
+	Token       string
+	Competition string // Competition code, e.g. "PL"
+	Season      string // Starting year of the season, e.g. "2024"
+	Client      *http.Client
+}
+
+// footballDataResponse mirrors the parts of the /competitions/{code}/matches response we use
+type footballDataResponse struct {
+	Competition struct {
+		Name string `json:"name"`
+	} `json:"competition"`
+	Matches []struct {
+		ID       int    `json:"id"`
+		UTCDate  string `json:"utcDate"`
+		Status   string `json:"status"`
+		Matchday *int   `json:"matchday"`
+		HomeTeam struct {
+			Name string `json:"name"`
+		} `json:"homeTeam"`
+		AwayTeam struct {
+			Name string `json:"name"`
+		} `json:"awayTeam"`
+		Score struct {
+			FullTime struct {
+				Home *int `json:"home"`
+				Away *int `json:"away"`
+			} `json:"fullTime"`
+		} `json:"score"`
+	} `json:"matches"`
+}
+
+func (p *FootballDataProvider) Name() string {
+	return ProviderFootballData
+}
+
+// FetchFixtures fetches the competition's matches for the configured season
+func (p *FootballDataProvider) FetchFixtures(ctx context.Context) ([]Fixture, error) {
+	if p.Token == "" {
+		return nil, fmt.Errorf("FOOTBALL_DATA_TOKEN environment variable not set")
+	}
+
+	url := fmt.Sprintf("%s/competitions/%s/matches?season=%s", p.BaseURL, p.Competition, p.Season)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	req.Header.Add("X-Auth-Token", p.Token)
+
+	body, err := doRequest(p.Client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResponse footballDataResponse
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %v", err)
+	}
+
+	var fixtures []Fixture
+
+	for _, item := range apiResponse.Matches {
+		date, err := time.Parse(time.RFC3339, item.UTCDate)
+		if err != nil {
+			log.Printf("Error parsing date %s: %v", item.UTCDate, err)
+			continue
+		}
+
+		matchDay := 0
+		if item.Matchday != nil {
+			matchDay = *item.Matchday
+		}
+
+		fixtures = append(fixtures, Fixture{
+			ExternalID: strconv.Itoa(item.ID),
+			HomeTeam:   item.HomeTeam.Name,
+			AwayTeam:   item.AwayTeam.Name,
+			Kickoff:    date,
+			League:     apiResponse.Competition.Name,
+			Season:     p.Season,
+			MatchDay:   matchDay,
+			HomeScore:  item.Score.FullTime.Home,
+			AwayScore:  item.Score.FullTime.Away,
+			Status:     footballDataStatus(item.Status),
+		})
+	}
+
+	return fixtures, nil
+}
+
+// footballDataStatus maps football-data.org statuses to match statuses, treating
+// suspended and awarded matches like apiSportsStatus does
+func footballDataStatus(status string) string {
+	switch status {
+	case "IN_PLAY", "PAUSED", "LIVE", "SUSPENDED":
+		return models.MatchStatusLive
+	case "FINISHED":
+		return models.MatchStatusFinished
+	case "POSTPONED":
+		return models.MatchStatusPostponed
+	case "CANCELLED", "AWARDED":
+		return models.MatchStatusCancelled
+	default: // "SCHEDULED", "TIMED"
+		return models.MatchStatusScheduled
+	}
+}
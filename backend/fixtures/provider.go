@@ -0,0 +1,102 @@
+package fixtures
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"ball-knowledge/config"
+	"ball-knowledge/models"
+)
+
+// Fixture is a provider-neutral view of a single fixture
+type Fixture struct {
+	ExternalID string    `json:"external_id"`
+	HomeTeam   string    `json:"home_team"`
+	AwayTeam   string    `json:"away_team"`
+	Kickoff    time.Time `json:"kickoff"`
+	League     string    `json:"league"`
+	Season     string    `json:"season"`
+	MatchDay   int       `json:"match_day"`
+	HomeScore  *int      `json:"home_score"`
+	AwayScore  *int      `json:"away_score"`
+	Status     string    `json:"status"` // One of the models.MatchStatus constants
+}
+
+// FixtureProvider is a source of fixtures for the sync service
+type FixtureProvider interface {
+	// Name identifies the provider in logs and stored data
+	Name() string
+	// FetchFixtures returns every fixture the provider knows about for the configured competition
+	FetchFixtures(ctx context.Context) ([]Fixture, error)
+}
+
+// Provider names accepted by FIXTURE_PROVIDER
+const (
+	ProviderAPISports    = "apisports"
+	ProviderFootballData = "footballdata"
+	ProviderFile         = "file"
+	ProviderFake         = "fake"
+)
+
+// provider is the source used by Run. It is set once at startup via SetProvider.
+var provider FixtureProvider
+
+// SetProvider sets the fixture provider used by Run
+func SetProvider(p FixtureProvider) {
+	provider = p
+}
+
+// NewProviderFromEnv builds the fixture provider selected by FIXTURE_PROVIDER.
+// Providers holding resources (such as the fake server) also implement io.Closer.
+func NewProviderFromEnv() (FixtureProvider, error) {
+	season := config.String("SEASON", "2024")
+
+	switch name := strings.ToLower(config.String("FIXTURE_PROVIDER", ProviderAPISports)); name {
+	case ProviderAPISports:
+		return &APISportsProvider{
+			BaseURL:  config.String("API_FOOTBALL_BASE_URL", defaultAPISportsBaseURL),
+			APIKey:   config.String("API_FOOTBALL_KEY", ""),
+			LeagueID: config.String("LEAGUE_ID", "39"), // Default to Premier League
+			Season:   season,
+		}, nil
+
+	case ProviderFootballData:
+		return &FootballDataProvider{
+			BaseURL:     config.String("FOOTBALL_DATA_BASE_URL", defaultFootballDataBaseURL),
+			Token:       config.String("FOOTBALL_DATA_TOKEN", ""),
+			Competition: config.String("FOOTBALL_DATA_COMPETITION", "PL"),
+			Season:      season,
+		}, nil
+
+	case ProviderFile:
+		path := config.String("FIXTURE_FILE", "")
+		if path == "" {
+			return nil, fmt.Errorf("FIXTURE_FILE must be set when FIXTURE_PROVIDER=file")
+		}
+		return &FileProvider{
+			Path:          path,
+			DefaultLeague: config.String("FIXTURE_FILE_LEAGUE", "Premier League"),
+			DefaultSeason: season,
+		}, nil
+
+	case ProviderFake:
+		fake, err := NewFakeProvider(config.String("FIXTURE_FAKE_PAYLOAD", ""), season)
+		if err != nil {
+			return nil, err
+		}
+		return fake, nil
+
+	default:
+		return nil, fmt.Errorf("unknown fixture provider %q", name)
+	}
+}
+
+// inferStatus guesses a status for fixtures whose source doesn't report one
+func inferStatus(fixture Fixture) string {
+	if fixture.HomeScore != nil && fixture.AwayScore != nil {
+		return models.MatchStatusFinished
+	}
+	return models.MatchStatusScheduled
+}
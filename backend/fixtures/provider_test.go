@@ -0,0 +1,31 @@
+package fixtures
+
+import "testing"
+
+// Both providers must map the same match state to the same status
+func TestProviderStatusesAgree(t *testing.T) {
+	equivalent := []struct {
+		apiSports, footballData, want string
+	}{
+		{"NS", "SCHEDULED", "scheduled"},
+		{"TBD", "TIMED", "scheduled"},
+		{"1H", "IN_PLAY", "live"},
+		{"HT", "PAUSED", "live"},
+		{"SUSP", "SUSPENDED", "live"},
+		{"INT", "SUSPENDED", "live"},
+		{"FT", "FINISHED", "finished"},
+		{"PST", "POSTPONED", "postponed"},
+		{"CANC", "CANCELLED", "cancelled"},
+		{"AWD", "AWARDED", "cancelled"},
+		{"WO", "AWARDED", "cancelled"},
+	}
+
+	for _, tt := range equivalent {
+		if got := apiSportsStatus(tt.apiSports); got != tt.want {
+			t.Errorf("api-sports %s maps to %s, want %s", tt.apiSports, got, tt.want)
+		}
+		if got := footballDataStatus(tt.footballData); got != tt.want {
+			t.Errorf("football-data %s maps to %s, want %s", tt.footballData, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package fixtures
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Scheduler runs fixture syncs in the background on a fixed interval
+type Scheduler struct {
+	interval    time.Duration
+	syncOnStart bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler. An interval of zero disables periodic syncs.
+func NewScheduler(interval time.Duration, syncOnStart bool) *Scheduler {
+	return &Scheduler{
+		interval:    interval,
+		syncOnStart: syncOnStart,
+	}
+}
+
+// Start launches the background sync loop. It returns immediately.
+func (s *Scheduler) Start(ctx context.Context) {
+	if s.interval <= 0 && !s.syncOnStart {
+		log.Println("⚠️  Fixture sync scheduler disabled")
+		return
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.wg.Add(1)
+	go s.loop(ctx)
+
+	if s.interval > 0 {
+		log.Printf("⏱️  Fixture sync scheduled every %s", s.interval)
+	}
+}
+
+// Stop cancels the sync loop and waits for any in-flight sync to finish
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+	log.Println("✅ Fixture sync scheduler stopped")
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	defer s.wg.Done()
+
+	if s.syncOnStart {
+		s.runOnce(ctx, TriggerStartup)
+	}
+
+	if s.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runOnce(ctx, TriggerScheduled)
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, trigger string) {
+	if _, err := Run(ctx, trigger); errors.Is(err, ErrSyncInProgress) {
+		log.Printf("⚠️  Skipping %s fixture sync: previous sync still running", trigger)
+	}
+}
@@ -0,0 +1,168 @@
+package fixtures
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+	"ball-knowledge/settlement"
+
+	"github.com/google/uuid"
+)
+
+// Sync triggers
+const (
+	TriggerStartup   = "startup"
+	TriggerScheduled = "scheduled"
+	TriggerManual    = "manual"
+)
+
+// ErrSyncInProgress is returned when a sync is requested while another one is running
+var ErrSyncInProgress = errors.New("a fixture sync is already in progress")
+
+// syncMu makes sure only one sync runs at a time, whoever triggered it
+var syncMu sync.Mutex
+
+// maxSyncErrors is how many fixture errors a failed run keeps in its error summary
+const maxSyncErrors = 5
+
+// syncCounts tallies what happened to the fetched fixtures during a run
+type syncCounts struct {
+	New     int
+	Updated int
+	Skipped int
+	Failed  int
+	Errors  []string // The first maxSyncErrors reasons fixtures failed to save
+}
+
+// failure summarises the fixtures that failed to save
+func (counts syncCounts) failure() error {
+	total := counts.New + counts.Updated + counts.Skipped + counts.Failed
+	return fmt.Errorf("%d of %d fixtures failed to save: %s",
+		counts.Failed, total, strings.Join(counts.Errors, "; "))
+}
+
+// Run performs a single fixture sync and records its outcome in the sync_runs table.
+// Cancelling ctx aborts any in-flight provider request.
+func Run(ctx context.Context, trigger string) (*models.SyncRun, error) {
+	if !syncMu.TryLock() {
+		return nil, ErrSyncInProgress
+	}
+	defer syncMu.Unlock()
+
+	run := models.SyncRun{
+		Trigger:   trigger,
+		Status:    models.SyncStatusRunning,
+		StartedAt: time.Now().UTC(),
+	}
+	if err := database.DB.Create(&run).Error; err != nil {
+		return nil, fmt.Errorf("failed to record sync run: %v", err)
+	}
+
+	counts, syncErr := fetchAndStoreMatches(ctx, run.ID)
+	if syncErr == nil {
+		syncErr = settlement.RecordSnapshots()
+	}
+	// The fixtures that did save are kept, but the run still fails so it gets looked at
+	if syncErr == nil && counts.Failed > 0 {
+		syncErr = counts.failure()
+	}
+
+	finishedAt := time.Now().UTC()
+	run.FinishedAt = &finishedAt
+	run.NewCount = counts.New
+	run.UpdatedCount = counts.Updated
+	run.SkippedCount = counts.Skipped
+	run.FailedCount = counts.Failed
+	run.Status = models.SyncStatusSuccess
+	if syncErr != nil {
+		run.Status = models.SyncStatusFailed
+		run.Error = syncErr.Error()
+	}
+
+	if err := database.DB.Save(&run).Error; err != nil {
+		log.Printf("❌ Failed to save sync run %s: %v", run.ID, err)
+	}
+
+	if syncErr != nil {
+		log.Printf("❌ Fixture sync (%s) failed: %v", trigger, syncErr)
+	} else {
+		log.Printf("✅ Fixture sync (%s): %d new, %d updated, %d skipped",
+			trigger, counts.New, counts.Updated, counts.Skipped)
+	}
+
+	return &run, syncErr
+}
+
+// fetchAndStoreMatches fetches fixtures from the configured provider and upserts them
+func fetchAndStoreMatches(ctx context.Context, runID uuid.UUID) (syncCounts, error) {
+	var counts syncCounts
+
+	if provider == nil {
+		return counts, errors.New("no fixture provider configured")
+	}
+
+	fetched, err := provider.FetchFixtures(ctx)
+	if err != nil {
+		return counts, fmt.Errorf("error fetching fixtures from %s: %v", provider.Name(), err)
+	}
+
+	for _, fixture := range fetched {
+		match := toMatch(fixture)
+		match.Provider = provider.Name()
+
+		outcome, err := upsertMatch(match, runID)
+		if err != nil {
+			log.Printf("Error saving match %s vs %s: %v", match.HomeTeam, match.AwayTeam, err)
+			counts.Failed++
+			if len(counts.Errors) < maxSyncErrors {
+				counts.Errors = append(counts.Errors, fmt.Sprintf("fixture %s (%s vs %s): %v",
+					match.ExternalID, match.HomeTeam, match.AwayTeam, err))
+			}
+			continue
+		}
+
+		switch outcome {
+		case upsertCreated:
+			counts.New++
+		case upsertUpdated:
+			counts.Updated++
+		default:
+			counts.Skipped++
+		}
+	}
+
+	return counts, nil
+}
+
+// toMatch converts a provider fixture into a match model
+func toMatch(fixture Fixture) models.Match {
+	// Build result string; unplayed matches have no result
+	result := ""
+	if fixture.HomeScore != nil && fixture.AwayScore != nil {
+		result = fmt.Sprintf("%d:%d", *fixture.HomeScore, *fixture.AwayScore)
+	}
+
+	status := fixture.Status
+	if !models.IsValidMatchStatus(status) {
+		status = inferStatus(fixture)
+	}
+
+	return models.Match{
+		HomeTeam:   fixture.HomeTeam,
+		AwayTeam:   fixture.AwayTeam,
+		Date:       fixture.Kickoff.UTC(),
+		League:     fixture.League,
+		Season:     fixture.Season,
+		MatchDay:   fixture.MatchDay,
+		Result:     result,
+		Status:     status,
+		ExternalID: fixture.ExternalID,
+	}
+}
@@ -0,0 +1,262 @@
+package fixtures
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ball-knowledge/database"
+	"ball-knowledge/database/databasetest"
+	"ball-knowledge/models"
+)
+
+// Fixture IDs in the recorded Premier League 2024 payload
+const (
+	finishedFixture  = "1208021" // Manchester United 1:0 Fulham
+	scheduledFixture = "1208031" // Brighton v Manchester United, not started
+)
+
+const recordedFixtures = 20
+
+func TestSyncCountsCreatedUpdatedAndUnchanged(t *testing.T) {
+	databasetest.Open(t)
+
+	run := syncPayload(t, nil)
+	assertCounts(t, run, recordedFixtures, 0, 0)
+
+	run = syncPayload(t, nil)
+	assertCounts(t, run, 0, 0, recordedFixtures)
+
+	run = syncPayload(t, func(fixture map[string]interface{}) {
+		if fixtureID(fixture) == scheduledFixture {
+			setScore(fixture, "FT", 2, 1)
+		}
+	})
+	assertCounts(t, run, 0, 1, recordedFixtures-1)
+
+	match := storedMatch(t, scheduledFixture)
+	if match.Status != models.MatchStatusFinished || match.Result != "2:1" {
+		t.Errorf("match is %s %q, want finished 2:1", match.Status, match.Result)
+	}
+
+	var changes []models.MatchChange
+	database.DB.Where("match_id = ? AND sync_run_id = ?", match.ID, run.ID).Find(&changes)
+	if len(changes) != 2 {
+		t.Errorf("logged %d changes, want result and status: %+v", len(changes), changes)
+	}
+}
+
+func TestSyncSkipsLockedMatches(t *testing.T) {
+	databasetest.Open(t)
+	syncPayload(t, nil)
+
+	match := storedMatch(t, finishedFixture)
+	database.DB.Model(&match).Updates(map[string]interface{}{"result": "2:0", "locked": true})
+
+	run := syncPayload(t, func(fixture map[string]interface{}) {
+		if fixtureID(fixture) == finishedFixture {
+			setScore(fixture, "FT", 3, 3)
+		}
+	})
+	assertCounts(t, run, 0, 0, recordedFixtures)
+
+	if match = storedMatch(t, finishedFixture); match.Result != "2:0" {
+		t.Errorf("locked match result is %q, want the manual 2:0", match.Result)
+	}
+}
+
+func TestSyncIgnoresIllegalStatusChanges(t *testing.T) {
+	databasetest.Open(t)
+	syncPayload(t, nil)
+
+	// A finished match can't go back to not started
+	run := syncPayload(t, func(fixture map[string]interface{}) {
+		if fixtureID(fixture) == finishedFixture {
+			fixture["fixture"].(map[string]interface{})["status"].(map[string]interface{})["short"] = "NS"
+		}
+	})
+	assertCounts(t, run, 0, 0, recordedFixtures)
+
+	if match := storedMatch(t, finishedFixture); match.Status != models.MatchStatusFinished {
+		t.Errorf("match status is %s, want finished", match.Status)
+	}
+}
+
+func TestSyncSettlesChangedResults(t *testing.T) {
+	databasetest.Open(t)
+	syncPayload(t, nil)
+
+	user := models.User{Username: "predictor", Email: "predictor@example.com", Password: "x", Role: models.RoleUser}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	match := storedMatch(t, scheduledFixture)
+	prediction := models.Prediction{UserID: user.ID, MatchID: match.ID, PredictedScoreHome: 2, PredictedScoreAway: 1}
+	if err := database.DB.Create(&prediction).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	syncPayload(t, func(fixture map[string]interface{}) {
+		if fixtureID(fixture) == scheduledFixture {
+			setScore(fixture, "FT", 2, 1)
+		}
+	})
+
+	database.DB.First(&prediction, "id = ?", prediction.ID)
+	if prediction.Points == 0 || !prediction.ExactScore || prediction.Breakdown == nil {
+		t.Fatalf("exact prediction wasn't settled: %d points, exact %v, breakdown %v",
+			prediction.Points, prediction.ExactScore, prediction.Breakdown)
+	}
+
+	var standing models.Standing
+	if err := database.DB.First(&standing, "user_id = ?", user.ID).Error; err != nil {
+		t.Fatalf("no standing for user: %v", err)
+	}
+	if standing.TotalPoints != prediction.Points {
+		t.Errorf("standing has %d points, want %d", standing.TotalPoints, prediction.Points)
+	}
+
+	// A corrected result re-settles the match
+	syncPayload(t, func(fixture map[string]interface{}) {
+		if fixtureID(fixture) == scheduledFixture {
+			setScore(fixture, "FT", 0, 1)
+		}
+	})
+
+	database.DB.First(&prediction, "id = ?", prediction.ID)
+	if prediction.Points != 0 || prediction.ExactScore || prediction.CorrectOutcome {
+		t.Errorf("wrong prediction still scores after the correction: %d points, exact %v, outcome %v",
+			prediction.Points, prediction.ExactScore, prediction.CorrectOutcome)
+	}
+}
+
+func TestSyncFailsWhenFixturesCannotBeSaved(t *testing.T) {
+	databasetest.Open(t)
+	rejectMatches(t, "NEW.external_id = '"+scheduledFixture+"'")
+
+	run, err := trySync(t, nil)
+	if run == nil {
+		t.Fatal(err)
+	}
+	if err == nil || run.Status != models.SyncStatusFailed {
+		t.Fatalf("sync with a fixture that can't be saved: status %s, err %v; want failed", run.Status, err)
+	}
+	if run.NewCount != recordedFixtures-1 || run.FailedCount != 1 {
+		t.Errorf("sync counted %d new, %d failed; want %d, 1", run.NewCount, run.FailedCount, recordedFixtures-1)
+	}
+	if !strings.Contains(run.Error, "1 of 20 fixtures failed") || !strings.Contains(run.Error, scheduledFixture) {
+		t.Errorf("run error %q doesn't say which fixture failed", run.Error)
+	}
+
+	var stored models.SyncRun
+	database.DB.First(&stored, "id = ?", run.ID)
+	if stored.Status != models.SyncStatusFailed || stored.FailedCount != 1 {
+		t.Errorf("stored run is %s with %d failed, want failed with 1", stored.Status, stored.FailedCount)
+	}
+}
+
+func TestSyncFailsWhenNoFixtureCanBeSaved(t *testing.T) {
+	databasetest.Open(t)
+	rejectMatches(t, "1")
+
+	run, err := trySync(t, nil)
+	if run == nil {
+		t.Fatal(err)
+	}
+	if err == nil || run.Status != models.SyncStatusFailed {
+		t.Fatalf("sync that saved nothing: status %s, err %v; want failed", run.Status, err)
+	}
+	assertCounts(t, run, 0, 0, 0)
+	if run.FailedCount != recordedFixtures {
+		t.Errorf("sync counted %d failed, want %d", run.FailedCount, recordedFixtures)
+	}
+}
+
+// rejectMatches makes inserting matches fail when condition holds for the new row
+func rejectMatches(t *testing.T, condition string) {
+	t.Helper()
+	if err := database.DB.Exec("CREATE TRIGGER reject_matches BEFORE INSERT ON matches WHEN " + condition +
+		" BEGIN SELECT RAISE(ABORT, 'rejected by test'); END").Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// syncPayload runs a sync against the fake api-sports server, serving the recorded
+// payload with edit applied to every fixture in it
+func syncPayload(t *testing.T, edit func(fixture map[string]interface{})) *models.SyncRun {
+	t.Helper()
+	run, err := trySync(t, edit)
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	return run
+}
+
+// trySync is syncPayload for runs that may fail
+func trySync(t *testing.T, edit func(fixture map[string]interface{})) (*models.SyncRun, error) {
+	t.Helper()
+
+	raw, err := os.ReadFile(filepath.Join("fakeapi", "recordings", "fixtures_39_2024.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edit != nil {
+		var payload map[string]interface{}
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			t.Fatal(err)
+		}
+		for _, fixture := range payload["response"].([]interface{}) {
+			edit(fixture.(map[string]interface{}))
+		}
+		if raw, err = json.Marshal(payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fake, err := NewFakeProvider(path, "2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+	SetProvider(fake)
+	defer SetProvider(nil)
+
+	return Run(context.Background(), TriggerManual)
+}
+
+func assertCounts(t *testing.T, run *models.SyncRun, created, updated, skipped int) {
+	t.Helper()
+	if run.NewCount != created || run.UpdatedCount != updated || run.SkippedCount != skipped {
+		t.Errorf("sync counted %d new, %d updated, %d skipped; want %d, %d, %d",
+			run.NewCount, run.UpdatedCount, run.SkippedCount, created, updated, skipped)
+	}
+}
+
+func storedMatch(t *testing.T, externalID string) models.Match {
+	t.Helper()
+	var match models.Match
+	if err := database.DB.Where("provider = ? AND external_id = ?", ProviderFake, externalID).First(&match).Error; err != nil {
+		t.Fatalf("match %s not stored: %v", externalID, err)
+	}
+	return match
+}
+
+func fixtureID(fixture map[string]interface{}) string {
+	id, _ := json.Marshal(fixture["fixture"].(map[string]interface{})["id"])
+	return string(id)
+}
+
+// setScore sets a fixture's status code and full-time score
+func setScore(fixture map[string]interface{}, short string, home, away int) {
+	fixture["fixture"].(map[string]interface{})["status"].(map[string]interface{})["short"] = short
+	fixture["score"].(map[string]interface{})["fulltime"] = map[string]interface{}{"home": home, "away": away}
+	fixture["goals"] = map[string]interface{}{"home": home, "away": away}
+}
@@ -0,0 +1,103 @@
+package fixtures
+
+import (
+	"errors"
+	"log"
+
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+	"ball-knowledge/settlement"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type upsertOutcome int
+
+const (
+	upsertUnchanged upsertOutcome = iota
+	upsertCreated
+	upsertUpdated
+)
+
+// upsertMatch creates the match or updates the stored copy, logging every changed field.
+//
+// Matches are keyed on the provider's fixture ID. Matches stored before
+// external IDs were tracked are found by teams and date and adopted.
+func upsertMatch(incoming models.Match, runID uuid.UUID) (upsertOutcome, error) {
+	outcome := upsertUnchanged
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		existing, err := findExistingMatch(tx, incoming)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			outcome = upsertCreated
+			return tx.Create(&incoming).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		// Matches corrected by hand keep the correction until an admin unlocks them
+		if existing.Locked {
+			return nil
+		}
+
+		// Providers occasionally report impossible transitions (e.g. finished back to
+		// scheduled); keep the stored status rather than reopen the match
+		if !models.CanTransition(existing.Status, incoming.Status) {
+			log.Printf("⚠️  Ignoring illegal status change %s -> %s for match %s",
+				existing.Status, incoming.Status, existing.ID)
+			incoming.Status = existing.Status
+		}
+
+		changes := models.DiffMatch(existing, incoming)
+		if len(changes) == 0 {
+			return nil
+		}
+
+		for i := range changes {
+			changes[i].MatchID = existing.ID
+			changes[i].SyncRunID = &runID
+		}
+
+		incoming.ID = existing.ID
+		if err := tx.Save(&incoming).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&changes).Error; err != nil {
+			return err
+		}
+
+		// A new or corrected result, or a change of competition or gameweek, changes
+		// everyone's points on this match
+		if models.AffectsScoring(changes) {
+			if _, err := settlement.SettleMatch(tx, existing.ID); err != nil {
+				return err
+			}
+		}
+
+		outcome = upsertUpdated
+		return nil
+	})
+
+	return outcome, err
+}
+
+// findExistingMatch looks up the stored copy of a fetched match
+func findExistingMatch(tx *gorm.DB, incoming models.Match) (models.Match, error) {
+	var existing models.Match
+
+	if incoming.ExternalID != "" {
+		err := tx.Where("provider = ? AND external_id = ?", incoming.Provider, incoming.ExternalID).
+			First(&existing).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return existing, err
+		}
+	}
+
+	// Fall back to matches imported without an external ID
+	err := tx.Where("external_id = '' OR external_id IS NULL").
+		Where("home_team = ? AND away_team = ? AND date = ?", incoming.HomeTeam, incoming.AwayTeam, incoming.Date).
+		First(&existing).Error
+	return existing, err
+}
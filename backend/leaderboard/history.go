@@ -0,0 +1,185 @@
+package leaderboard
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// gameweek identifies one round of a competition
+type gameweek struct {
+	League   string
+	Season   string
+	MatchDay int
+}
+
+// RecordSnapshots snapshots the overall standings for every completed gameweek
+// that hasn't been snapshotted yet. A gameweek is complete once all its matches
+// are finished or cancelled. The snapshot covers every match kicking off up to the
+// gameweek's last kickoff, so it can be retaken after results change.
+func RecordSnapshots() (int, error) {
+	var completed []gameweek
+	if err := database.DB.Table("matches").
+		Select("league, season, match_day").
+		Where("match_day > 0").
+		Where("NOT EXISTS (SELECT 1 FROM rank_snapshots WHERE rank_snapshots.league = matches.league "+
+			"AND rank_snapshots.season = matches.season AND rank_snapshots.match_day = matches.match_day)").
+		Group("league, season, match_day").
+		Having("SUM(CASE WHEN status IN ? THEN 0 ELSE 1 END) = 0 AND SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) > 0",
+			[]string{models.MatchStatusFinished, models.MatchStatusCancelled}, models.MatchStatusFinished).
+		Scan(&completed).Error; err != nil {
+		return 0, fmt.Errorf("failed to find completed gameweeks: %v", err)
+	}
+
+	recorded := 0
+	for _, gw := range completed {
+		if err := recordSnapshot(gw); err != nil {
+			return recorded, err
+		}
+		recorded++
+	}
+
+	if recorded > 0 {
+		log.Printf("✅ Recorded rank snapshots for %d completed gameweeks", recorded)
+	}
+	return recorded, nil
+}
+
+func recordSnapshot(gw gameweek) error {
+	var last models.Match
+	if err := database.DB.Where("league = ? AND season = ? AND match_day = ?", gw.League, gw.Season, gw.MatchDay).
+		Order("date DESC").
+		First(&last).Error; err != nil {
+		return fmt.Errorf("failed to load gameweek %d of %s %s: %v", gw.MatchDay, gw.League, gw.Season, err)
+	}
+	cutoff := last.Date.Add(time.Second)
+
+	entries, err := Build(Options{To: cutoff})
+	if err != nil {
+		return fmt.Errorf("failed to build standings for gameweek %d of %s %s: %v", gw.MatchDay, gw.League, gw.Season, err)
+	}
+
+	snapshots := make([]models.RankSnapshot, 0, len(entries))
+	for _, entry := range entries {
+		userID, err := uuid.Parse(entry.UserID)
+		if err != nil {
+			return fmt.Errorf("invalid user ID %q on leaderboard: %v", entry.UserID, err)
+		}
+		snapshots = append(snapshots, models.RankSnapshot{
+			UserID:      userID,
+			League:      gw.League,
+			Season:      gw.Season,
+			MatchDay:    gw.MatchDay,
+			Cutoff:      cutoff,
+			Rank:        entry.Rank,
+			TotalPoints: entry.TotalPoints,
+		})
+	}
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	return database.DB.CreateInBatches(&snapshots, 100).Error
+}
+
+// InvalidateSnapshots deletes the snapshots that include a match kicking off at kickoff,
+// so RecordSnapshots retakes them with the match's new points
+func InvalidateSnapshots(tx *gorm.DB, kickoff time.Time) error {
+	return tx.Where("cutoff > ?", kickoff.UTC()).Delete(&models.RankSnapshot{}).Error
+}
+
+// History returns a user's snapshots in order, optionally for one league and season
+func History(userID uuid.UUID, league, season string) ([]models.RankSnapshot, error) {
+	history := []models.RankSnapshot{}
+
+	query := database.DB.Where("user_id = ?", userID)
+	if league != "" {
+		query = query.Where("league = ?", league)
+	}
+	if season != "" {
+		query = query.Where("season = ?", season)
+	}
+
+	err := query.Order("cutoff ASC").Find(&history).Error
+	return history, err
+}
+
+// previousRanks returns the given users' ranks going into the gameweek being played now
+// (or most recently played): the latest snapshot taken before its first kickoff.
+// It returns nil when there is no such snapshot.
+func previousRanks(userIDs []string) (map[string]int, error) {
+	var current models.Match
+	err := database.DB.Where("match_day > 0 AND date <= ?", time.Now().UTC()).
+		Order("date DESC").
+		First(&current).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var first models.Match
+	if err := database.DB.Where("league = ? AND season = ? AND match_day = ?", current.League, current.Season, current.MatchDay).
+		Order("date ASC").
+		First(&first).Error; err != nil {
+		return nil, err
+	}
+
+	var baseline models.RankSnapshot
+	err = database.DB.Where("cutoff <= ?", first.Date).Order("cutoff DESC").First(&baseline).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []models.RankSnapshot
+	if err := database.DB.Where("league = ? AND season = ? AND match_day = ?", baseline.League, baseline.Season, baseline.MatchDay).
+		Where("user_id IN ?", userIDs).
+		Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+
+	ranks := make(map[string]int, len(snapshots))
+	for _, snapshot := range snapshots {
+		ranks[snapshot.UserID.String()] = snapshot.Rank
+	}
+	return ranks, nil
+}
+
+// addMovement sets each entry's previous rank and how many places they moved since.
+// Users who weren't ranked then have neither.
+func addMovement(entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	userIDs := make([]string, len(entries))
+	for i := range entries {
+		userIDs[i] = entries[i].UserID
+	}
+
+	ranks, err := previousRanks(userIDs)
+	if err != nil || ranks == nil {
+		return err
+	}
+
+	for i := range entries {
+		previous, ok := ranks[entries[i].UserID]
+		if !ok {
+			continue
+		}
+		movement := previous - entries[i].Rank // Positive means up
+		entries[i].PreviousRank = &previous
+		entries[i].Movement = &movement
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package leaderboard
+
+import (
+	"time"
+
+	"ball-knowledge/config"
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Entry is one user's row on a leaderboard
+type Entry struct {
+	UserID          string    `json:"user_id"`
+	Username        string    `json:"username"`
+	TotalPoints     int       `json:"total_points"`
+	PredictionCount int       `json:"prediction_count"`
+	ExactScores     int       `json:"exact_scores"`
+	CorrectOutcomes int       `json:"correct_outcomes"`
+	RegisteredAt    time.Time `json:"registered_at"`
+	Rank            int       `json:"rank"`
+	PreviousRank    *int      `json:"previous_rank"` // Rank going into the current gameweek; overall standings only
+	Movement        *int      `json:"movement"`      // Places gained (positive) or lost since PreviousRank
+}
+
+// Options narrows which users and which predictions appear on a leaderboard.
+// Zero values don't filter.
+type Options struct {
+	MiniLeagueID *uuid.UUID // Only members of this private league
+	League       string     // Only matches of this competition
+	Season       string     // Only matches of this season
+	GameWeek     int        // Only matches of this gameweek
+	From         time.Time  // Only matches kicking off at or after From
+	To           time.Time  // Only matches kicking off before To
+	Ranking      string     // RankingCompetition (default) or RankingDense
+}
+
+// filtersMatches reports whether the leaderboard only counts some matches
+func (opts Options) filtersMatches() bool {
+	return opts.League != "" || opts.Season != "" || opts.GameWeek > 0 || !opts.From.IsZero() || !opts.To.IsZero()
+}
+
+// scopeMatches applies the match filters to a query that includes the matches table
+func (opts Options) scopeMatches(query *gorm.DB) *gorm.DB {
+	if opts.League != "" {
+		query = query.Where("matches.league = ?", opts.League)
+	}
+	if opts.Season != "" {
+		query = query.Where("matches.season = ?", opts.Season)
+	}
+	if opts.GameWeek > 0 {
+		query = query.Where("matches.match_day = ?", opts.GameWeek)
+	}
+	if !opts.From.IsZero() {
+		query = query.Where("matches.date >= ?", opts.From.UTC())
+	}
+	if !opts.To.IsZero() {
+		query = query.Where("matches.date < ?", opts.To.UTC())
+	}
+	return query
+}
+
+// VerifiedOnly reports whether users who haven't verified their email are left off leaderboards
+func VerifiedOnly() bool {
+	return config.Bool("LEADERBOARD_VERIFIED_ONLY", false)
+}
+
+// Build computes a leaderboard of every participant, ranked by points and then the
+// configured tie-breakers. Participants are all registered users, or a mini-league's
+// members; those without predictions in scope appear with zero points. With
+// LEADERBOARD_VERIFIED_ONLY, users with an unverified email are left out.
+// All-time leaderboards are served from the standings table; period leaderboards
+// aggregate the predictions in the period. Use Page or Around to read part of one.
+func Build(opts Options) ([]Entry, error) {
+	var rows []rankedEntry
+	if err := ranked(opts).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return entries(opts, rows)
+}
+
+// Page returns one page of a leaderboard, pages starting at 1, and the number of participants.
+// Ranking and paging happen in the database, so only the page is loaded.
+func Page(opts Options, page, limit int) ([]Entry, int64, error) {
+	var total int64
+	if err := database.DB.Table("(?) AS participants", participants(opts)).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []rankedEntry
+	if err := ranked(opts).Limit(limit).Offset((page - 1) * limit).Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	pageEntries, err := entries(opts, rows)
+	return pageEntries, total, err
+}
+
+// Around finds userID on a leaderboard and returns their entry along with up to n
+// entries either side of it, and the number of participants. ok is false when the
+// user isn't a participant.
+func Around(opts Options, userID string, n int) (entry Entry, neighbours []Entry, total int64, ok bool, err error) {
+	var me rankedEntry
+	result := ranked(opts).Where("user_id = ?", userID).Limit(1).Scan(&me)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return Entry{}, nil, 0, false, result.Error
+	}
+
+	if err := database.DB.Table("(?) AS participants", participants(opts)).Count(&total).Error; err != nil {
+		return Entry{}, nil, 0, false, err
+	}
+
+	var rows []rankedEntry
+	if err := ranked(opts).Where("position BETWEEN ? AND ?", me.Position-n, me.Position+n).Scan(&rows).Error; err != nil {
+		return Entry{}, nil, 0, false, err
+	}
+	if neighbours, err = entries(opts, rows); err != nil {
+		return Entry{}, nil, 0, false, err
+	}
+	for _, neighbour := range neighbours {
+		if neighbour.UserID == userID {
+			entry = neighbour
+		}
+	}
+	return entry, neighbours, total, true, nil
+}
+
+// Winners returns the entries ranked first; several when they are level after every
+// tie-breaker. Nobody wins a period in which nobody scored.
+func Winners(opts Options) ([]Entry, error) {
+	var rows []rankedEntry
+	if err := ranked(opts).Where("rank = 1 AND total_points > 0").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return entries(opts, rows)
+}
+
+// rankedEntry is a leaderboard row along with its place in display order
+type rankedEntry struct {
+	Entry
+	Position int
+}
+
+// participants selects every participant's totals, unranked
+func participants(opts Options) *gorm.DB {
+	var query *gorm.DB
+	if opts.filtersMatches() {
+		query = periodTotals(opts)
+	} else {
+		query = database.DB.Table("users").
+			Select("users.id as user_id, users.username, users.created_at as registered_at, " +
+				"COALESCE(standings.total_points, 0) as total_points, COALESCE(standings.prediction_count, 0) as prediction_count, " +
+				"COALESCE(standings.exact_scores, 0) as exact_scores, COALESCE(standings.correct_outcomes, 0) as correct_outcomes").
+			Joins("LEFT JOIN standings ON standings.user_id = users.id")
+	}
+
+	if VerifiedOnly() {
+		query = query.Where("users.email_verified = ?", true)
+	}
+
+	if opts.MiniLeagueID != nil {
+		query = query.Where("users.id IN (?)", database.DB.Table("mini_league_members").
+			Select("user_id").
+			Where("league_id = ?", *opts.MiniLeagueID))
+	}
+	return query
+}
+
+// ranked selects the ranked participants in display order, for narrowing down further
+func ranked(opts Options) *gorm.DB {
+	ranking := database.DB.Table("(?) AS participants", participants(opts)).
+		Select("participants.*, " + rankColumns(Tiebreakers(), opts.Ranking))
+	return database.DB.Table("(?) AS ranked", ranking).Order("position ASC")
+}
+
+// entries returns the leaderboard entries of ranked rows. Snapshots record the overall
+// standings with competition ranking, so only those show movement.
+func entries(opts Options, rows []rankedEntry) ([]Entry, error) {
+	result := make([]Entry, len(rows))
+	for i, row := range rows {
+		result[i] = row.Entry
+	}
+
+	if !opts.filtersMatches() && opts.MiniLeagueID == nil && opts.Ranking != RankingDense {
+		if err := addMovement(result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// periodTotals aggregates each user's predictions on the matches in scope.
+// Predictions on cancelled matches don't count.
+func periodTotals(opts Options) *gorm.DB {
+	return database.DB.Table("users").
+		Select("users.id as user_id, users.username, users.created_at as registered_at, "+
+			"COALESCE(SUM(predictions.points), 0) as total_points, COUNT(predictions.id) as prediction_count, "+
+			"SUM(CASE WHEN predictions.exact_score THEN 1 ELSE 0 END) as exact_scores, "+
+			"SUM(CASE WHEN predictions.correct_outcome THEN 1 ELSE 0 END) as correct_outcomes").
+		Joins("LEFT JOIN predictions ON predictions.user_id = users.id AND predictions.voided = ? AND predictions.match_id IN (?)",
+			false, opts.scopeMatches(database.DB.Table("matches").Select("matches.id"))).
+		Group("users.id, users.username, users.created_at")
+}
+
+// Complete reports whether every match the leaderboard covers has been settled,
+// i.e. the standings, and so the winners, can no longer change
+func Complete(opts Options) (bool, error) {
+	var total, open int64
+
+	if err := opts.scopeMatches(database.DB.Table("matches")).Count(&total).Error; err != nil {
+		return false, err
+	}
+	if err := opts.scopeMatches(database.DB.Table("matches")).
+		Where("matches.status NOT IN ?", []string{models.MatchStatusFinished, models.MatchStatusCancelled}).
+		Count(&open).Error; err != nil {
+		return false, err
+	}
+
+	return total > 0 && open == 0, nil
+}
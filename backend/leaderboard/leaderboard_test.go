@@ -0,0 +1,151 @@
+package leaderboard
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ball-knowledge/database"
+	"ball-knowledge/database/databasetest"
+	"ball-knowledge/models"
+)
+
+// seedStandings registers users with the given standings, a minute apart in this order
+func seedStandings(t *testing.T, standings map[string]models.Standing, order ...string) map[string]string {
+	t.Helper()
+
+	ids := make(map[string]string)
+	registered := time.Date(2024, 8, 1, 12, 0, 0, 0, time.UTC)
+	for i, name := range order {
+		user := models.User{
+			Username:  name,
+			Email:     name + "@example.com",
+			Password:  "x",
+			Role:      models.RoleUser,
+			CreatedAt: registered.Add(time.Duration(i) * time.Minute),
+		}
+		if err := database.DB.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = user.ID.String()
+
+		if standing, ok := standings[name]; ok {
+			standing.UserID = user.ID
+			if err := database.DB.Create(&standing).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return ids
+}
+
+func seedTable(t *testing.T) map[string]string {
+	return seedStandings(t, map[string]models.Standing{
+		"dave":  {TotalPoints: 12, PredictionCount: 5, ExactScores: 1, CorrectOutcomes: 4},
+		"frank": {TotalPoints: 10, PredictionCount: 5, ExactScores: 3, CorrectOutcomes: 3},
+		"alice": {TotalPoints: 10, PredictionCount: 5, ExactScores: 2, CorrectOutcomes: 3},
+		"bob":   {TotalPoints: 10, PredictionCount: 5, ExactScores: 2, CorrectOutcomes: 3},
+	}, "bob", "alice", "dave", "frank", "erin")
+}
+
+func describe(entries []Entry) string {
+	parts := make([]string, len(entries))
+	for i, entry := range entries {
+		parts[i] = entry.Username + ":" + strconv.Itoa(entry.Rank)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestBuildRanksByPointsThenTiebreakers(t *testing.T) {
+	databasetest.Open(t)
+	seedTable(t)
+
+	entries, err := Build(Options{Ranking: RankingCompetition})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// frank has more exact scores; bob registered before alice; erin has no standing
+	if got, want := describe(entries), "dave:1 frank:2 bob:3 alice:4 erin:5"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if entries[4].TotalPoints != 0 || entries[2].RegisteredAt.IsZero() {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestBuildSharesRanksWithoutTiebreakers(t *testing.T) {
+	databasetest.Open(t)
+	seedTable(t)
+	t.Setenv("LEADERBOARD_TIEBREAKERS", "none")
+
+	entries, err := Build(Options{Ranking: RankingCompetition})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := describe(entries), "dave:1 alice:2 bob:2 frank:2 erin:5"; got != want {
+		t.Errorf("competition ranking: got %s, want %s", got, want)
+	}
+
+	entries, err = Build(Options{Ranking: RankingDense})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := describe(entries), "dave:1 alice:2 bob:2 frank:2 erin:3"; got != want {
+		t.Errorf("dense ranking: got %s, want %s", got, want)
+	}
+}
+
+func TestPageAndAround(t *testing.T) {
+	databasetest.Open(t)
+	ids := seedTable(t)
+	opts := Options{Ranking: RankingCompetition}
+
+	page, total, err := Page(opts, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := describe(page), "bob:3 alice:4"; got != want || total != 5 {
+		t.Errorf("page 2: got %s of %d, want %s of 5", got, total, want)
+	}
+
+	page, _, err = Page(opts, 4, 2)
+	if err != nil || len(page) != 0 {
+		t.Errorf("page past the end: got %v, %v", page, err)
+	}
+
+	entry, neighbours, total, ok, err := Around(opts, ids["bob"], 1)
+	if err != nil || !ok {
+		t.Fatalf("bob not found: %v", err)
+	}
+	if entry.Username != "bob" || entry.Rank != 3 || total != 5 {
+		t.Errorf("got %s:%d of %d, want bob:3 of 5", entry.Username, entry.Rank, total)
+	}
+	if got, want := describe(neighbours), "frank:2 bob:3 alice:4"; got != want {
+		t.Errorf("neighbours: got %s, want %s", got, want)
+	}
+
+	if _, _, _, ok, err := Around(opts, "nobody", 1); ok || err != nil {
+		t.Errorf("unknown user: ok %v, err %v", ok, err)
+	}
+
+	winners, err := Winners(opts)
+	if err != nil || describe(winners) != "dave:1" {
+		t.Errorf("winners: got %s, %v", describe(winners), err)
+	}
+}
+
+func TestVerifiedOnlyLeavesOutUnverifiedUsers(t *testing.T) {
+	databasetest.Open(t)
+	ids := seedTable(t)
+	database.DB.Model(&models.User{}).Where("id <> ?", ids["dave"]).Update("email_verified", true)
+	t.Setenv("LEADERBOARD_VERIFIED_ONLY", "true")
+
+	page, total, err := Page(Options{Ranking: RankingCompetition}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := describe(page), "frank:1 bob:2 alice:3 erin:4"; got != want || total != 4 {
+		t.Errorf("got %s of %d, want %s of 4", got, total, want)
+	}
+}
@@ -0,0 +1,81 @@
+package leaderboard
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"ball-knowledge/config"
+)
+
+// Tie-breakers, applied in the configured order when users are level on points
+const (
+	TiebreakExactScores          = "exact_scores"          // More exact scores ranks higher
+	TiebreakCorrectOutcomes      = "correct_outcomes"      // More correct outcomes ranks higher
+	TiebreakFewestPredictions    = "fewest_predictions"    // Fewer predictions ranks higher (better points per prediction)
+	TiebreakEarliestRegistration = "earliest_registration" // Registering earlier ranks higher
+)
+
+// DefaultTiebreakers is the tie-break order used unless LEADERBOARD_TIEBREAKERS overrides it
+var DefaultTiebreakers = []string{
+	TiebreakExactScores,
+	TiebreakCorrectOutcomes,
+	TiebreakFewestPredictions,
+	TiebreakEarliestRegistration,
+}
+
+// Ranking styles
+const (
+	RankingCompetition = "competition" // Tied users share a rank and the next rank is skipped: 1, 2, 2, 4
+	RankingDense       = "dense"       // Tied users share a rank and no rank is skipped: 1, 2, 2, 3
+)
+
+// tiebreakers orders participants on one criterion, higher ranked first, in SQL
+var tiebreakers = map[string]string{
+	TiebreakExactScores:          "exact_scores DESC",
+	TiebreakCorrectOutcomes:      "correct_outcomes DESC",
+	TiebreakFewestPredictions:    "prediction_count ASC",
+	TiebreakEarliestRegistration: "registered_at ASC",
+}
+
+// IsValidRanking reports whether ranking is a known ranking style
+func IsValidRanking(ranking string) bool {
+	return ranking == RankingCompetition || ranking == RankingDense
+}
+
+// Tiebreakers returns the configured tie-break order. LEADERBOARD_TIEBREAKERS is a
+// comma-separated list of criteria; "none" disables tie-breaking so level users share a rank.
+func Tiebreakers() []string {
+	names := config.List("LEADERBOARD_TIEBREAKERS", DefaultTiebreakers)
+
+	criteria := []string{}
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if name == "none" {
+			return []string{}
+		}
+		if _, ok := tiebreakers[name]; !ok {
+			log.Printf("⚠️  Ignoring unknown leaderboard tie-breaker %q", name)
+			continue
+		}
+		criteria = append(criteria, name)
+	}
+	return criteria
+}
+
+// rankColumns returns the SQL that ranks participants by points then the tie-breakers, and
+// numbers them in display order. Users level on points and every tie-breaker share a rank;
+// among them, usernames decide the order they're listed in.
+func rankColumns(criteria []string, ranking string) string {
+	order := "total_points DESC"
+	for _, name := range criteria {
+		order += ", " + tiebreakers[name]
+	}
+
+	rankFunction := "RANK()"
+	if ranking == RankingDense {
+		rankFunction = "DENSE_RANK()"
+	}
+	return fmt.Sprintf("%s OVER (ORDER BY %s) AS rank, ROW_NUMBER() OVER (ORDER BY %s, username ASC) AS position",
+		rankFunction, order, order)
+}
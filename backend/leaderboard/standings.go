@@ -0,0 +1,116 @@
+package leaderboard
+
+import (
+	"log"
+	"time"
+
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// standingDelta is how much a prediction adds to its user's standing
+type standingDelta struct {
+	points, predictions, exactScores, correctOutcomes int
+}
+
+func contribution(prediction *models.Prediction) standingDelta {
+	if prediction == nil || prediction.Voided {
+		return standingDelta{}
+	}
+
+	delta := standingDelta{points: prediction.Points, predictions: 1}
+	if prediction.ExactScore {
+		delta.exactScores = 1
+	}
+	if prediction.CorrectOutcome {
+		delta.correctOutcomes = 1
+	}
+	return delta
+}
+
+// UpdateStanding adjusts a user's standing for a prediction changing from before to after
+// using tx. before is nil for a new prediction, after is nil for a deleted one.
+func UpdateStanding(tx *gorm.DB, userID uuid.UUID, before, after *models.Prediction) error {
+	old, updated := contribution(before), contribution(after)
+	delta := standingDelta{
+		points:          updated.points - old.points,
+		predictions:     updated.predictions - old.predictions,
+		exactScores:     updated.exactScores - old.exactScores,
+		correctOutcomes: updated.correctOutcomes - old.correctOutcomes,
+	}
+	if delta == (standingDelta{}) {
+		return nil
+	}
+
+	standing := models.Standing{
+		UserID:          userID,
+		TotalPoints:     delta.points,
+		PredictionCount: delta.predictions,
+		ExactScores:     delta.exactScores,
+		CorrectOutcomes: delta.correctOutcomes,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"total_points":     gorm.Expr("standings.total_points + ?", delta.points),
+			"prediction_count": gorm.Expr("standings.prediction_count + ?", delta.predictions),
+			"exact_scores":     gorm.Expr("standings.exact_scores + ?", delta.exactScores),
+			"correct_outcomes": gorm.Expr("standings.correct_outcomes + ?", delta.correctOutcomes),
+			"updated_at":       time.Now().UTC(),
+		}),
+	}).Create(&standing).Error
+}
+
+// RebuildStandings recomputes every standing from the predictions table,
+// recovering from standings that drifted, e.g. after predictions were edited by hand
+func RebuildStandings() (int64, error) {
+	var rebuilt int64
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.Standing{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Exec(`INSERT INTO standings (user_id, total_points, prediction_count, exact_scores, correct_outcomes, updated_at)
+			SELECT predictions.user_id, SUM(predictions.points), COUNT(predictions.id),
+				SUM(CASE WHEN predictions.exact_score THEN 1 ELSE 0 END),
+				SUM(CASE WHEN predictions.correct_outcome THEN 1 ELSE 0 END), ?
+			FROM predictions
+			JOIN users ON users.id = predictions.user_id
+			WHERE predictions.voided = ?
+			GROUP BY predictions.user_id`, time.Now().UTC(), false)
+		rebuilt = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("✅ Rebuilt leaderboard standings for %d users", rebuilt)
+	return rebuilt, nil
+}
+
+// EnsureStandings builds the standings if there are predictions but no standings yet,
+// as on the first start after standings were introduced
+func EnsureStandings() error {
+	var standings, predictions int64
+	if err := database.DB.Model(&models.Standing{}).Count(&standings).Error; err != nil {
+		return err
+	}
+	if standings > 0 {
+		return nil
+	}
+	if err := database.DB.Model(&models.Prediction{}).Count(&predictions).Error; err != nil {
+		return err
+	}
+	if predictions == 0 {
+		return nil
+	}
+
+	_, err := RebuildStandings()
+	return err
+}
@@ -0,0 +1,59 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LogMailer writes messages to the server log instead of sending them, for development
+type LogMailer struct{}
+
+func (m *LogMailer) Name() string {
+	return DriverLog
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	log.Printf("📧 Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer writes each message to an .eml file in Dir, for tests and local development
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *FileMailer) Name() string {
+	return DriverFile
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), sanitize(msg.To))
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, format(m.From, msg), 0o600); err != nil {
+		return err
+	}
+	log.Printf("📧 Mail to %s written to %s", msg.To, path)
+	return nil
+}
+
+// sanitize keeps an address safe to use in a file name
+func sanitize(address string) string {
+	out := make([]rune, 0, len(address))
+	for _, r := range address {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_', r == '@':
+			out = append(out, r)
+		default:
+			out = append(out, '_')
+		}
+	}
+	return string(out)
+}
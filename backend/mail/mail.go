@@ -0,0 +1,89 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"ball-knowledge/config"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email
+type Mailer interface {
+	// Name identifies the mailer in logs
+	Name() string
+	// Send delivers a message
+	Send(msg Message) error
+}
+
+// Mailer names accepted by MAIL_DRIVER
+const (
+	DriverLog  = "log"
+	DriverFile = "file"
+	DriverSMTP = "smtp"
+)
+
+// mailer delivers mail sent with Send. It is set once at startup via SetMailer.
+var mailer Mailer = &LogMailer{}
+
+// SetMailer sets the mailer used by Send
+func SetMailer(m Mailer) {
+	mailer = m
+}
+
+// Send delivers a message with the configured mailer
+func Send(msg Message) error {
+	if err := mailer.Send(msg); err != nil {
+		return fmt.Errorf("%s mailer: %v", mailer.Name(), err)
+	}
+	return nil
+}
+
+// SendAsync delivers a message in the background, logging failures. Use it where the
+// response must not reveal whether mail was sent, e.g. to unknown addresses.
+func SendAsync(msg Message) {
+	go func() {
+		if err := Send(msg); err != nil {
+			log.Printf("⚠️  Failed to send %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
+
+// NewMailerFromEnv builds the mailer selected by MAIL_DRIVER
+func NewMailerFromEnv() (Mailer, error) {
+	from := config.String("MAIL_FROM", "Ball Knowledge <no-reply@localhost>")
+
+	switch name := strings.ToLower(config.String("MAIL_DRIVER", DriverLog)); name {
+	case DriverLog:
+		return &LogMailer{}, nil
+
+	case DriverFile:
+		return &FileMailer{
+			Dir:  config.String("MAIL_DIR", "mail"),
+			From: from,
+		}, nil
+
+	case DriverSMTP:
+		host := config.String("SMTP_HOST", "")
+		if host == "" {
+			return nil, fmt.Errorf("SMTP_HOST must be set when MAIL_DRIVER=smtp")
+		}
+		return &SMTPMailer{
+			Host:     host,
+			Port:     config.Int("SMTP_PORT", 587),
+			Username: config.String("SMTP_USERNAME", ""),
+			Password: config.String("SMTP_PASSWORD", ""),
+			From:     from,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", name)
+	}
+}
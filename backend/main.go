@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -10,9 +11,17 @@ import (
 	"path/filepath"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Embed the timezone database for ?tz= on minimal hosts
 
+	"ball-knowledge/auth"
+	"ball-knowledge/config"
 	"ball-knowledge/database"
+	"ball-knowledge/fixtures"
+	"ball-knowledge/leaderboard"
+	"ball-knowledge/mail"
 	"ball-knowledge/routes"
+	"ball-knowledge/sessions"
+	"ball-knowledge/settlement"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -33,7 +42,9 @@ func init() {
 	log.Printf("🌐 Server Mode: %s", getEnvWithDefault("GIN_MODE", "debug"))
 
 	// Check if API key is set (don't log the actual key)
-	if os.Getenv("API_FOOTBALL_KEY") != "" {
+	if provider := getEnvWithDefault("FIXTURE_PROVIDER", "apisports"); provider != "apisports" {
+		log.Printf("⚽ Fixture provider %s selected via FIXTURE_PROVIDER", provider)
+	} else if os.Getenv("API_FOOTBALL_KEY") != "" {
 		log.Println("✅ Football API key is configured")
 	} else {
 		log.Println("⚠️  Warning: API_FOOTBALL_KEY not set - match fetching will fail")
@@ -41,6 +52,11 @@ func init() {
 }
 
 func main() {
+	// Run a maintenance command instead of the server, e.g. "ball-knowledge rescore -season 2024"
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	log.Println("🚀 Starting Ball Knowledge API Server...")
 
 	// Set Gin mode
@@ -48,6 +64,11 @@ func main() {
 		gin.SetMode(mode)
 	}
 
+	// Load the JWT signing keys; release mode requires configured keys
+	if err := auth.Init(); err != nil {
+		log.Fatalf("❌ Failed to load JWT keys: %v", err)
+	}
+
 	// Initialize database
 	if err := database.ConnectDatabase(); err != nil {
 		log.Fatalf("❌ Failed to connect to database: %v", err)
@@ -58,6 +79,28 @@ func main() {
 		}
 	}()
 
+	// Make BOOTSTRAP_ADMIN_EMAIL the first admin if they've already registered and verified their email
+	if err := database.PromoteBootstrapAdmin(); err != nil {
+		log.Printf("⚠️  Warning: failed to promote bootstrap admin: %v", err)
+	}
+
+	// Build the leaderboard standings if they've never been built
+	if err := leaderboard.EnsureStandings(); err != nil {
+		log.Printf("⚠️  Warning: failed to build leaderboard standings: %v", err)
+	}
+
+	// Drop refresh tokens that can no longer be used
+	if pruned, err := sessions.PruneExpired(); err != nil {
+		log.Printf("⚠️  Warning: failed to prune expired refresh tokens: %v", err)
+	} else if pruned > 0 {
+		log.Printf("🧹 Pruned %d expired refresh tokens", pruned)
+	}
+
+	// Score predictions left unsettled, e.g. from before points breakdowns were stored
+	if _, err := settlement.SettleUnscored(); err != nil {
+		log.Printf("⚠️  Warning: failed to settle unscored predictions: %v", err)
+	}
+
 	// Setup router
 	router := setupRouter()
 
@@ -67,6 +110,7 @@ func main() {
 	// Setup API routes
 	api := router.Group("/api")
 	routes.SetupRoutes(api)
+	routes.SetupWellKnownRoutes(router)
 
 	// Get port from environment
 	port := getEnvWithDefault("PORT", "8081")
@@ -80,11 +124,71 @@ func main() {
 		log.Printf("📝 API Documentation:")
 		log.Printf("   POST /api/register          - Register new user")
 		log.Printf("   POST /api/login             - User login")
+		log.Printf("   POST /api/login/2fa         - Finish a login with a TOTP or recovery code")
+		log.Printf("   GET  /api/auth/oidc/login   - Log in with the OIDC identity provider")
+		log.Printf("   POST /api/refresh-token     - Exchange a refresh token for new tokens")
+		log.Printf("   POST /api/logout            - Revoke a refresh token (\"all\": true for every login)")
+		log.Printf("   POST /api/forgot-password   - Email a password reset link")
+		log.Printf("   POST /api/reset-password    - Set a new password with a reset token")
+		log.Printf("   POST /api/verify-email      - Verify an email address with its link token")
+		log.Printf("   POST /api/resend-verification - Resend the verification email (auth)")
+		log.Printf("   POST /api/2fa/enroll        - Start two-factor enrolment (auth)")
+		log.Printf("   POST /api/2fa/confirm       - Enable two-factor authentication with a code (auth)")
+		log.Printf("   POST /api/2fa/disable       - Turn off two-factor authentication (auth)")
+		log.Printf("   POST /api/2fa/recovery-codes - Replace recovery codes (auth)")
 		log.Printf("   GET  /api/matches           - Get all matches")
 		log.Printf("   POST /api/predictions       - Create prediction (auth)")
-		log.Printf("   GET  /api/leaderboard       - View leaderboard")
+		log.Printf("   GET  /api/leaderboard       - View leaderboard (?season=, ?gameweek=, ?month=YYYY-MM, ?page=, ?limit=)")
+		log.Printf("   GET  /api/leaderboard/me    - Your rank and neighbours (auth)")
+		log.Printf("   GET  /api/leaderboard/history/:userId - Rank after each gameweek")
 		log.Printf("   GET  /api/profile           - Get user profile (auth)")
+		log.Printf("   POST /api/leagues           - Create private league (auth)")
+		log.Printf("   POST /api/leagues/join      - Join league by invite code (auth)")
+		log.Printf("   GET  /api/leagues/:id/leaderboard - League leaderboard (auth)")
+		log.Printf("   GET  /api/admin/sync-runs   - Fixture sync history (moderator)")
+		log.Printf("   POST /api/admin/sync        - Trigger fixture sync (moderator)")
+		log.Printf("   GET  /api/admin/match-changes - Match change log (moderator)")
+		log.Printf("   PUT  /api/admin/matches/:id - Edit a match (moderator)")
+		log.Printf("   PUT  /api/admin/matches/:id/result - Set or correct a final score (moderator)")
+		log.Printf("   PUT  /api/admin/matches/:id/status - Change a match status (moderator)")
+		log.Printf("   POST /api/admin/matches/:id/reschedule - Reschedule a match (moderator)")
+		log.Printf("   DELETE /api/admin/matches/:id/lock - Let fixture syncs update a match again (moderator)")
+		log.Printf("   DELETE /api/admin/matches/:id - Delete a match and its predictions (admin)")
+		log.Printf("   GET  /api/admin/audit-log   - Audit log of admin actions (admin)")
+		log.Printf("   GET  /api/admin/security-events - Failed logins, lockouts and other security events (admin)")
+		log.Printf("   DELETE /api/admin/users/:id/lockout - Clear a user's login lockout (admin)")
+		log.Printf("   DELETE /api/admin/users/:id/2fa - Reset a user's two-factor authentication (admin)")
+		log.Printf("   POST /api/admin/rescore     - Re-score predictions (admin)")
+		log.Printf("   POST /api/admin/leaderboard/rebuild - Rebuild leaderboard standings (admin)")
+		log.Printf("   GET  /api/scoring/rules     - Scoring rules for a competition")
+		log.Printf("   GET  /.well-known/jwks.json - Public keys that verify access tokens")
+	}
+
+	// Configure the fixture provider
+	provider, err := fixtures.NewProviderFromEnv()
+	if err != nil {
+		log.Fatalf("❌ Failed to configure fixture provider: %v", err)
+	}
+	if closer, ok := provider.(io.Closer); ok {
+		defer closer.Close()
 	}
+	fixtures.SetProvider(provider)
+	log.Printf("⚽ Fixture provider: %s", provider.Name())
+
+	// Configure outgoing mail
+	mailer, err := mail.NewMailerFromEnv()
+	if err != nil {
+		log.Fatalf("❌ Failed to configure mail: %v", err)
+	}
+	mail.SetMailer(mailer)
+	log.Printf("📧 Mailer: %s", mailer.Name())
+
+	// Start background fixture sync
+	scheduler := fixtures.NewScheduler(
+		config.Duration("FIXTURE_SYNC_INTERVAL", 6*time.Hour),
+		config.Bool("FIXTURE_SYNC_ON_START", true),
+	)
+	scheduler.Start(context.Background())
 
 	// Graceful shutdown
 	srv := &http.Server{
@@ -106,6 +210,9 @@ func main() {
 
 	log.Println("🛑 Shutting down server...")
 
+	// Stop scheduling new syncs and wait for a running one to finish
+	scheduler.Stop()
+
 	// Give outstanding requests 5 seconds to complete
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
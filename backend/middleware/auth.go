@@ -3,18 +3,13 @@ package middleware
 import (
 	"errors"
 	"net/http"
-	"os"
 	"strings"
 
+	"ball-knowledge/auth"
+
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 )
 
-type Claims struct {
-	UserID string `json:"user_id"`
-	jwt.RegisteredClaims
-}
-
 // AuthMiddleware validates JWT tokens
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -35,13 +30,9 @@ func AuthMiddleware() gin.HandlerFunc {
 		tokenString = tokenString[7:]
 
 		// Parse and validate token
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(getJWTSecret()), nil
-		})
-
+		claims, err := auth.ParseAccessToken(tokenString)
 		if err != nil {
-			if errors.Is(err, jwt.ErrSignatureInvalid) {
+			if errors.Is(err, auth.ErrInvalidSignature) {
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token signature"})
 			} else {
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
@@ -50,14 +41,10 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		if !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is not valid"})
-			c.Abort()
-			return
-		}
-
-		// Set user ID in context for use in handlers
+		// Set user ID and role in context for use in handlers
 		c.Set("userID", claims.UserID)
+		c.Set("role", claims.Role)
+		c.Set("mfa", claims.MFA)
 		c.Next()
 	}
 }
@@ -70,24 +57,13 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 		if tokenString != "" && strings.HasPrefix(tokenString, "Bearer ") {
 			tokenString = tokenString[7:]
 
-			claims := &Claims{}
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-				return []byte(getJWTSecret()), nil
-			})
-
-			if err == nil && token.Valid {
+			if claims, err := auth.ParseAccessToken(tokenString); err == nil {
 				c.Set("userID", claims.UserID)
+				c.Set("role", claims.Role)
+				c.Set("mfa", claims.MFA)
 			}
 		}
 
 		c.Next()
 	}
 }
-
-func getJWTSecret() string {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return "fallback_secret_for_development_only"
-	}
-	return secret
-}
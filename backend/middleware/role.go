@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+
+	"ball-knowledge/auth"
+	"ball-knowledge/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole allows only users whose role grants at least the privileges of role.
+// It must run after AuthMiddleware, which puts the token's role in the context.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get("userID"); !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		if !models.HasRole(c.GetString("role"), role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		// Roles that must use two-factor authentication can't act on a password-only login
+		if auth.RequiresTwoFactor(c.GetString("role")) && !c.GetBool("mfa") {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":               "Two-factor authentication required",
+				"two_factor_required": true,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
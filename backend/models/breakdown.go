@@ -0,0 +1,51 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// PointsBreakdown explains how a prediction's points were awarded
+type PointsBreakdown struct {
+	RuleSet        string                `json:"rule_set"`
+	RuleSetVersion int                   `json:"rule_set_version"`
+	Outcome        int                   `json:"outcome"`
+	ExactScore     int                   `json:"exact_score"`
+	TotalGoals     int                   `json:"total_goals"`
+	GoalDifference int                   `json:"goal_difference"`
+	Closeness      int                   `json:"closeness"`
+	Multipliers    []BreakdownMultiplier `json:"multipliers"`
+	Total          int                   `json:"total"`
+}
+
+// BreakdownMultiplier is a multiplier applied on top of the rule points
+type BreakdownMultiplier struct {
+	Name   string `json:"name"`
+	Factor int    `json:"factor"`
+	Points int    `json:"points"` // Bonus points the multiplier added
+}
+
+// Value stores the breakdown as JSON text
+func (b PointsBreakdown) Value() (driver.Value, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a breakdown stored as JSON text
+func (b *PointsBreakdown) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*b = PointsBreakdown{}
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), b)
+	case []byte:
+		return json.Unmarshal(v, b)
+	default:
+		return fmt.Errorf("cannot scan %T into PointsBreakdown", value)
+	}
+}
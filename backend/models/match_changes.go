@@ -0,0 +1,47 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+// DiffMatch returns a change entry for every stored field that differs
+func DiffMatch(existing, incoming Match) []MatchChange {
+	var changes []MatchChange
+
+	compare := func(field, oldValue, newValue string) {
+		if oldValue != newValue {
+			changes = append(changes, MatchChange{
+				Field:    field,
+				OldValue: oldValue,
+				NewValue: newValue,
+			})
+		}
+	}
+
+	compare("home_team", existing.HomeTeam, incoming.HomeTeam)
+	compare("away_team", existing.AwayTeam, incoming.AwayTeam)
+	compare("date", existing.Date.UTC().Format(time.RFC3339), incoming.Date.UTC().Format(time.RFC3339))
+	compare("league", existing.League, incoming.League)
+	compare("season", existing.Season, incoming.Season)
+	compare("match_day", strconv.Itoa(existing.MatchDay), strconv.Itoa(incoming.MatchDay))
+	compare("result", existing.Result, incoming.Result)
+	compare("status", existing.Status, incoming.Status)
+	compare("provider", existing.Provider, incoming.Provider)
+	compare("external_id", existing.ExternalID, incoming.ExternalID)
+
+	return changes
+}
+
+// AffectsScoring reports whether any change requires the match to be re-settled. Besides
+// the result and status, the league and season pick the scoring rules and the match day
+// decides gameweek multipliers.
+func AffectsScoring(changes []MatchChange) bool {
+	for _, change := range changes {
+		switch change.Field {
+		case "result", "status", "league", "season", "match_day":
+			return true
+		}
+	}
+	return false
+}
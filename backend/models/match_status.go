@@ -0,0 +1,62 @@
+package models
+
+import "fmt"
+
+// Match statuses
+const (
+	MatchStatusScheduled = "scheduled"
+	MatchStatusLive      = "live"
+	MatchStatusFinished  = "finished"
+	MatchStatusPostponed = "postponed"
+	MatchStatusCancelled = "cancelled"
+)
+
+// ScheduledMatchStatuses are the statuses of matches played, or to be played, on their date.
+// Postponed and cancelled matches keep a date they won't be played on.
+var ScheduledMatchStatuses = []string{MatchStatusScheduled, MatchStatusLive, MatchStatusFinished}
+
+// matchStatusTransitions lists the statuses each status may move to
+var matchStatusTransitions = map[string][]string{
+	MatchStatusScheduled: {MatchStatusLive, MatchStatusFinished, MatchStatusPostponed, MatchStatusCancelled},
+	MatchStatusLive:      {MatchStatusFinished, MatchStatusPostponed, MatchStatusCancelled},
+	MatchStatusPostponed: {MatchStatusScheduled, MatchStatusLive, MatchStatusFinished, MatchStatusCancelled},
+	MatchStatusFinished:  {MatchStatusCancelled},
+	MatchStatusCancelled: {},
+}
+
+// IsValidMatchStatus reports whether status is a known match status
+func IsValidMatchStatus(status string) bool {
+	_, ok := matchStatusTransitions[status]
+	return ok
+}
+
+// CanTransition reports whether a match may move from one status to another.
+// Staying in the same status is always allowed.
+func CanTransition(from, to string) bool {
+	if from == to {
+		return IsValidMatchStatus(to)
+	}
+	for _, allowed := range matchStatusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionTo moves the match to a new status, rejecting illegal transitions
+func (match *Match) TransitionTo(status string) error {
+	if !IsValidMatchStatus(status) {
+		return fmt.Errorf("unknown match status %q", status)
+	}
+	if !CanTransition(match.Status, status) {
+		return fmt.Errorf("match cannot move from %s to %s", match.Status, status)
+	}
+	match.Status = status
+	return nil
+}
+
+// AcceptsPredictions reports whether predictions can still be made on the match
+func (match *Match) AcceptsPredictions() bool {
+	return match.Status == MatchStatusScheduled || match.Status == MatchStatusPostponed
+}
@@ -1,33 +1,52 @@
 package models
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type User struct {
-	ID       uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
-	Username string    `gorm:"uniqueIndex;not null" json:"username" binding:"required"`
-	Email    string    `gorm:"uniqueIndex;not null" json:"email" binding:"required"`
-	Password string    `gorm:"not null" json:"password" binding:"required"`
+	ID        uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	Username  string    `gorm:"uniqueIndex;not null" json:"username" binding:"required"`
+	Email     string    `gorm:"uniqueIndex;not null" json:"email" binding:"required"`
+	Password  string    `gorm:"not null" json:"password" binding:"required"`
+	Role      string    `gorm:"not null;default:user;index" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+
+	EmailVerified      bool       `gorm:"not null;default:false;index" json:"email_verified"`
+	VerificationSentAt *time.Time `json:"-"` // When the last verification email was sent, for throttling resends
+
+	TOTPEnabled  bool   `gorm:"column:totp_enabled;not null;default:false" json:"two_factor_enabled"`
+	TOTPSecret   string `gorm:"column:totp_secret" json:"-"`    // Base32; set during enrolment, before TOTPEnabled
+	TOTPLastStep int64  `gorm:"column:totp_last_step" json:"-"` // Time step of the last accepted code, so codes can't be replayed
 }
 
 func (user *User) BeforeCreate(tx *gorm.DB) (err error) {
 	if user.ID == uuid.Nil {
 		user.ID = uuid.New()
 	}
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
 	return
 }
 
 type Match struct {
-	ID       uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
-	HomeTeam string    `gorm:"not null" json:"home_team" binding:"required"`
-	AwayTeam string    `gorm:"not null" json:"away_team" binding:"required"`
-	Date     string    `gorm:"not null" json:"date" binding:"required"`
-	League   string    `gorm:"not null" json:"league" binding:"required"`
-	Season   string    `gorm:"not null" json:"season" binding:"required"`
-	MatchDay int       `gorm:"not null" json:"match_day" binding:"required"`
-	Result   string    `json:"result"` // Stores the full-time score in "home:away" format
+	ID         uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	HomeTeam   string    `gorm:"not null" json:"home_team" binding:"required"`
+	AwayTeam   string    `gorm:"not null" json:"away_team" binding:"required"`
+	Date       time.Time `gorm:"not null;index" json:"date" binding:"required"` // Kickoff, stored in UTC
+	League     string    `gorm:"not null" json:"league" binding:"required"`
+	Season     string    `gorm:"not null" json:"season" binding:"required"`
+	MatchDay   int       `gorm:"not null" json:"match_day" binding:"required"`
+	Result     string    `json:"result"`                                                // Stores the full-time score in "home:away" format
+	Status     string    `gorm:"not null;default:scheduled;index" json:"status"`        // One of the MatchStatus constants
+	Provider   string    `gorm:"index:idx_match_external" json:"provider,omitempty"`    // Fixture provider the match was imported from
+	ExternalID string    `gorm:"index:idx_match_external" json:"external_id,omitempty"` // The provider's fixture ID
+	Locked     bool      `gorm:"not null;default:false" json:"locked"`                  // Set by manual edits; fixture syncs leave locked matches alone
 }
 
 func (match *Match) BeforeCreate(tx *gorm.DB) (err error) {
@@ -37,15 +56,44 @@ func (match *Match) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+func (match *Match) BeforeSave(tx *gorm.DB) (err error) {
+	match.Date = match.Date.UTC()
+	return
+}
+
+// MatchChange records a single field changed on a match by a fixture sync or an admin
+type MatchChange struct {
+	ID        uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	MatchID   uuid.UUID  `gorm:"type:char(36);not null;index" json:"match_id"`
+	SyncRunID *uuid.UUID `gorm:"type:char(36);index" json:"sync_run_id"`
+	UserID    *uuid.UUID `gorm:"type:char(36);index" json:"user_id"` // Admin who made a manual change
+	Field     string     `gorm:"not null" json:"field"`
+	OldValue  string     `json:"old_value"`
+	NewValue  string     `json:"new_value"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (change *MatchChange) BeforeCreate(tx *gorm.DB) (err error) {
+	if change.ID == uuid.Nil {
+		change.ID = uuid.New()
+	}
+	return
+}
+
 type Prediction struct {
-	ID                 uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
-	UserID             uuid.UUID `gorm:"type:char(36);not null;index:idx_user_match,unique" json:"user_id"`
-	MatchID            uuid.UUID `gorm:"type:char(36);not null;index:idx_user_match,unique" json:"match_id"`
-	PredictedScoreHome int       `gorm:"not null" json:"predicted_score_home" binding:"required"`
-	PredictedScoreAway int       `gorm:"not null" json:"predicted_score_away" binding:"required"`
-	Points             int       `gorm:"default:0" json:"points"`
-	User               User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
-	Match              Match     `gorm:"foreignKey:MatchID;constraint:OnDelete:CASCADE" json:"-"`
+	ID                 uuid.UUID        `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID             uuid.UUID        `gorm:"type:char(36);not null;index:idx_user_match,unique" json:"user_id"`
+	MatchID            uuid.UUID        `gorm:"type:char(36);not null;index:idx_user_match,unique" json:"match_id"`
+	PredictedScoreHome int              `gorm:"not null" json:"predicted_score_home" binding:"required"`
+	PredictedScoreAway int              `gorm:"not null" json:"predicted_score_away" binding:"required"`
+	Points             int              `gorm:"default:0" json:"points"`
+	Voided             bool             `gorm:"default:false" json:"voided"`                // Set when the match is cancelled; voided predictions never score
+	RuleSetID          *uuid.UUID       `gorm:"type:char(36)" json:"rule_set_id,omitempty"` // Scoring rule set version that produced Points
+	Breakdown          *PointsBreakdown `gorm:"type:text" json:"breakdown"`                 // How Points were awarded; nil until the match is settled
+	ExactScore         bool             `gorm:"default:false" json:"exact_score"`           // Predicted score matched the result, whatever the rule set
+	CorrectOutcome     bool             `gorm:"default:false" json:"correct_outcome"`       // Predicted winner (or draw) matched the result
+	User               User             `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Match              Match            `gorm:"foreignKey:MatchID;constraint:OnDelete:CASCADE" json:"-"`
 }
 
 func (prediction *Prediction) BeforeCreate(tx *gorm.DB) (err error) {
@@ -53,4 +101,265 @@ func (prediction *Prediction) BeforeCreate(tx *gorm.DB) (err error) {
 		prediction.ID = uuid.New()
 	}
 	return
-}
\ No newline at end of file
+}
+
+// Sync run statuses
+const (
+	SyncStatusRunning = "running"
+	SyncStatusSuccess = "success"
+	SyncStatusFailed  = "failed"
+)
+
+// SyncRun records the outcome of a single fixture sync
+type SyncRun struct {
+	ID           uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	Trigger      string     `gorm:"not null" json:"trigger"` // "scheduled", "startup" or "manual"
+	Status       string     `gorm:"not null;index" json:"status"`
+	StartedAt    time.Time  `gorm:"not null;index" json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+	NewCount     int        `gorm:"default:0" json:"new_count"`
+	UpdatedCount int        `gorm:"default:0" json:"updated_count"`
+	SkippedCount int        `gorm:"default:0" json:"skipped_count"`
+	FailedCount  int        `gorm:"default:0" json:"failed_count"` // Fixtures that couldn't be saved
+	Error        string     `json:"error,omitempty"`
+}
+
+func (run *SyncRun) BeforeCreate(tx *gorm.DB) (err error) {
+	if run.ID == uuid.Nil {
+		run.ID = uuid.New()
+	}
+	return
+}
+
+// ScoringRuleSet is a stored, immutable version of a scoring rule set
+type ScoringRuleSet struct {
+	ID         uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	Name       string    `gorm:"not null;uniqueIndex:idx_rule_set_version" json:"name"`
+	Version    int       `gorm:"not null;uniqueIndex:idx_rule_set_version" json:"version"`
+	Definition string    `gorm:"type:text;not null" json:"definition"` // JSON-encoded scoring.RuleSet
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (ruleSet *ScoringRuleSet) BeforeCreate(tx *gorm.DB) (err error) {
+	if ruleSet.ID == uuid.Nil {
+		ruleSet.ID = uuid.New()
+	}
+	return
+}
+
+// CompetitionScoring selects the rule set used for a league, optionally for a single season
+type CompetitionScoring struct {
+	ID             uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	League         string    `gorm:"not null;uniqueIndex:idx_competition_scoring" json:"league"`
+	Season         string    `gorm:"not null;default:'';uniqueIndex:idx_competition_scoring" json:"season"` // Empty applies to every season
+	RuleSetName    string    `gorm:"not null" json:"rule_set_name"`
+	RuleSetVersion int       `gorm:"not null;default:0" json:"rule_set_version"` // 0 follows the latest version
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (scoring *CompetitionScoring) BeforeCreate(tx *gorm.DB) (err error) {
+	if scoring.ID == uuid.Nil {
+		scoring.ID = uuid.New()
+	}
+	return
+}
+
+// MiniLeague is a private league whose leaderboard only ranks its members.
+// Its invite code is only shown to the owner.
+type MiniLeague struct {
+	ID         uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	Name       string    `gorm:"not null" json:"name"`
+	OwnerID    uuid.UUID `gorm:"type:char(36);not null;index" json:"owner_id"`
+	InviteCode string    `gorm:"uniqueIndex;not null" json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	Owner      User      `gorm:"foreignKey:OwnerID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (league *MiniLeague) BeforeCreate(tx *gorm.DB) (err error) {
+	if league.ID == uuid.Nil {
+		league.ID = uuid.New()
+	}
+	return
+}
+
+// MiniLeagueMember links a user to a mini-league they belong to
+type MiniLeagueMember struct {
+	LeagueID uuid.UUID  `gorm:"type:char(36);primaryKey" json:"league_id"`
+	UserID   uuid.UUID  `gorm:"type:char(36);primaryKey;index" json:"user_id"`
+	JoinedAt time.Time  `gorm:"autoCreateTime" json:"joined_at"`
+	League   MiniLeague `gorm:"foreignKey:LeagueID;constraint:OnDelete:CASCADE" json:"-"`
+	User     User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// RankSnapshot is a user's overall rank and points when a gameweek completed,
+// used for rank movement and rank-over-time charts
+type RankSnapshot struct {
+	ID          uuid.UUID `gorm:"type:char(36);primaryKey" json:"-"`
+	UserID      uuid.UUID `gorm:"type:char(36);not null;uniqueIndex:idx_snapshot_user_gameweek" json:"user_id"`
+	League      string    `gorm:"not null;uniqueIndex:idx_snapshot_user_gameweek" json:"league"`
+	Season      string    `gorm:"not null;uniqueIndex:idx_snapshot_user_gameweek" json:"season"`
+	MatchDay    int       `gorm:"not null;uniqueIndex:idx_snapshot_user_gameweek" json:"gameweek"`
+	Cutoff      time.Time `gorm:"not null;index" json:"cutoff"` // Standings include matches kicking off before Cutoff
+	Rank        int       `gorm:"not null" json:"rank"`
+	TotalPoints int       `gorm:"not null" json:"total_points"`
+	CreatedAt   time.Time `json:"created_at"`
+	User        User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (snapshot *RankSnapshot) BeforeCreate(tx *gorm.DB) (err error) {
+	if snapshot.ID == uuid.Nil {
+		snapshot.ID = uuid.New()
+	}
+	return
+}
+
+// Standing holds a user's all-time leaderboard totals, kept up to date as
+// predictions are made and settled so the leaderboard needn't aggregate every prediction
+type Standing struct {
+	UserID          uuid.UUID `gorm:"type:char(36);primaryKey" json:"user_id"`
+	TotalPoints     int       `gorm:"not null;default:0" json:"total_points"`
+	PredictionCount int       `gorm:"not null;default:0" json:"prediction_count"` // Predictions that aren't voided
+	ExactScores     int       `gorm:"not null;default:0" json:"exact_scores"`
+	CorrectOutcomes int       `gorm:"not null;default:0" json:"correct_outcomes"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	User            User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// AuditLog records an administrative action: who did what to which entity
+type AuditLog struct {
+	ID         uuid.UUID       `gorm:"type:char(36);primaryKey" json:"id"`
+	ActorID    *uuid.UUID      `gorm:"type:char(36);index" json:"actor_id"` // nil for actions taken by the system
+	Action     string          `gorm:"not null;index" json:"action"`        // e.g. "match.update"
+	EntityType string          `gorm:"not null;index:idx_audit_entity" json:"entity_type"`
+	EntityID   string          `gorm:"index:idx_audit_entity" json:"entity_id"`
+	Details    json.RawMessage `gorm:"type:text" json:"details"`
+	CreatedAt  time.Time       `gorm:"index" json:"created_at"`
+}
+
+func (log *AuditLog) BeforeCreate(tx *gorm.DB) (err error) {
+	if log.ID == uuid.Nil {
+		log.ID = uuid.New()
+	}
+	return
+}
+
+// RefreshToken is a single-use refresh token. Only a hash of the token is stored.
+// Tokens rotated from the same login share a family, which is revoked as a whole
+// when a used token is presented again.
+type RefreshToken struct {
+	ID        uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:char(36);not null;index" json:"user_id"`
+	FamilyID  uuid.UUID  `gorm:"type:char(36);not null;index" json:"family_id"`
+	TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`                           // Set when the token is exchanged for a new one
+	RevokedAt *time.Time `json:"revoked_at"`                        // Set on logout or reuse detection
+	MFA       bool       `gorm:"not null;default:false" json:"mfa"` // The login passed two-factor authentication
+	UserAgent string     `json:"user_agent"`
+	IPAddress string     `json:"ip_address"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (token *RefreshToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if token.ID == uuid.Nil {
+		token.ID = uuid.New()
+	}
+	return
+}
+
+// PasswordResetToken is a single-use token for resetting a forgotten password.
+// Only a hash of the token is stored.
+type PasswordResetToken struct {
+	ID        uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:char(36);not null;index" json:"user_id"`
+	TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (token *PasswordResetToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if token.ID == uuid.Nil {
+		token.ID = uuid.New()
+	}
+	return
+}
+
+// LoginThrottle tracks recent failed logins for one account or client IP, or a client
+// IP's recent password reset requests
+type LoginThrottle struct {
+	Key           string     `gorm:"primaryKey" json:"key"` // "account:<id or identifier>", "ip:<address>" or "reset:ip:<address>"
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
+
+// SecurityEvent records a security-relevant event, such as a failed login or a lockout
+type SecurityEvent struct {
+	ID        uuid.UUID       `gorm:"type:char(36);primaryKey" json:"id"`
+	Type      string          `gorm:"not null;index" json:"type"` // e.g. "login.failed"
+	UserID    *uuid.UUID      `gorm:"type:char(36);index" json:"user_id"`
+	IPAddress string          `gorm:"index" json:"ip_address"`
+	Details   json.RawMessage `gorm:"type:text" json:"details"`
+	CreatedAt time.Time       `gorm:"index" json:"created_at"`
+}
+
+func (event *SecurityEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	return
+}
+
+// RecoveryCode is a single-use code for logging in without the TOTP device.
+// Only a hash of the code is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:char(36);not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"not null;index" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (code *RecoveryCode) BeforeCreate(tx *gorm.DB) (err error) {
+	if code.ID == uuid.Nil {
+		code.ID = uuid.New()
+	}
+	return
+}
+
+// UserIdentity links a user to an account at an external OpenID Connect provider
+type UserIdentity struct {
+	ID          uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID      uuid.UUID `gorm:"type:char(36);not null;index" json:"user_id"`
+	Issuer      string    `gorm:"not null;uniqueIndex:idx_identity_issuer_subject" json:"issuer"`
+	Subject     string    `gorm:"not null;uniqueIndex:idx_identity_issuer_subject" json:"subject"` // The provider's stable user ID
+	Email       string    `json:"email"`                                                           // The email the provider reported at the last login
+	LastLoginAt time.Time `json:"last_login_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (identity *UserIdentity) BeforeCreate(tx *gorm.DB) (err error) {
+	if identity.ID == uuid.Nil {
+		identity.ID = uuid.New()
+	}
+	return
+}
+
+// OIDCLoginState is a started OpenID Connect login, looked up by the state parameter
+// when the provider redirects back. Only a hash of the state is stored.
+type OIDCLoginState struct {
+	ID           uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	StateHash    string    `gorm:"not null;uniqueIndex" json:"-"`
+	Nonce        string    `gorm:"not null" json:"-"` // Must match the ID token's nonce claim
+	CodeVerifier string    `gorm:"not null" json:"-"` // PKCE verifier for the code exchange
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (state *OIDCLoginState) BeforeCreate(tx *gorm.DB) (err error) {
+	if state.ID == uuid.Nil {
+		state.ID = uuid.New()
+	}
+	return
+}
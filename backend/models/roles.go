@@ -0,0 +1,30 @@
+package models
+
+// User roles, from least to most privileged
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator" // Manages matches and fixtures
+	RoleAdmin     = "admin"     // Everything, including scoring, settlement and user roles
+)
+
+// roleLevels ranks roles so a role includes the privileges of every role below it
+var roleLevels = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// IsValidRole reports whether role is a known role
+func IsValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// HasRole reports whether role grants at least the privileges of required.
+// An empty role, as in tokens issued before roles existed, is a plain user.
+func HasRole(role, required string) bool {
+	if role == "" {
+		role = RoleUser
+	}
+	return roleLevels[role] >= roleLevels[required]
+}
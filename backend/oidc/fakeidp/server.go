@@ -0,0 +1,278 @@
+// Package fakeidp is a minimal OpenID Connect provider for trying OIDC login locally,
+// without a real identity provider. It signs every authorization request in as a
+// configured user without asking, or as the email in a login_hint parameter.
+package fakeidp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"ball-knowledge/auth"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// User is who the provider signs in
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+}
+
+// Options configures the provider
+type Options struct {
+	Issuer       string // URL the provider is reachable at, e.g. http://localhost:9400
+	ClientID     string
+	ClientSecret string // When set, the token endpoint requires it; otherwise PKCE alone
+	User         User
+}
+
+// Provider is a fake OpenID Connect provider
+type Provider struct {
+	opts  Options
+	key   *rsa.PrivateKey
+	keyID string
+
+	mu     sync.Mutex
+	grants map[string]grant // Outstanding authorization codes
+}
+
+// grant is an authorization code waiting to be exchanged
+type grant struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	user        User
+	expiresAt   time.Time
+}
+
+// New creates a provider with a fresh RSA signing key
+func New(opts Options) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("error generating signing key: %v", err)
+	}
+	opts.Issuer = strings.TrimSuffix(opts.Issuer, "/")
+
+	// Each run has a new key, so it needs a new key ID for clients that cached the last one
+	sum := sha256.Sum256(key.PublicKey.N.Bytes())
+	keyID := "fakeidp-" + hex.EncodeToString(sum[:6])
+
+	return &Provider{opts: opts, key: key, keyID: keyID, grants: make(map[string]grant)}, nil
+}
+
+// Handler serves the provider's endpoints
+func (p *Provider) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.handleDiscovery)
+	mux.HandleFunc("/authorize", p.handleAuthorize)
+	mux.HandleFunc("/token", p.handleToken)
+	mux.HandleFunc("/jwks", p.handleJWKS)
+	return mux
+}
+
+func (p *Provider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.opts.Issuer,
+		"authorization_endpoint":                p.opts.Issuer + "/authorize",
+		"token_endpoint":                        p.opts.Issuer + "/token",
+		"jwks_uri":                              p.opts.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+	})
+}
+
+func (p *Provider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, auth.JWKSet{Keys: []auth.JWK{{
+		KeyType:   "RSA",
+		KeyID:     p.keyID,
+		Use:       "sig",
+		Algorithm: "RS256",
+		N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+// handleAuthorize approves every valid request straight away and redirects back with a code
+func (p *Provider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI := query.Get("redirect_uri")
+	target, err := url.Parse(redirectURI)
+	if err != nil || redirectURI == "" || query.Get("client_id") != p.opts.ClientID {
+		// Without a trusted redirect URI errors can only be shown here
+		http.Error(w, "unknown client_id or invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	reply := target.Query()
+	reply.Set("state", query.Get("state"))
+	switch {
+	case query.Get("response_type") != "code":
+		reply.Set("error", "unsupported_response_type")
+	case !strings.Contains(" "+query.Get("scope")+" ", " openid "):
+		reply.Set("error", "invalid_scope")
+	case query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256":
+		reply.Set("error", "invalid_request")
+		reply.Set("error_description", "PKCE with S256 is required")
+	default:
+		code, err := randomToken()
+		if err != nil {
+			reply.Set("error", "server_error")
+			break
+		}
+		p.mu.Lock()
+		p.grants[code] = grant{
+			redirectURI: redirectURI,
+			challenge:   query.Get("code_challenge"),
+			nonce:       query.Get("nonce"),
+			user:        p.userFor(query.Get("login_hint")),
+			expiresAt:   time.Now().Add(time.Minute),
+		}
+		p.mu.Unlock()
+		reply.Set("code", code)
+	}
+
+	target.RawQuery = reply.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// userFor is the configured user, or for a login_hint email a user with that address
+func (p *Provider) userFor(loginHint string) User {
+	if loginHint == "" || !strings.Contains(loginHint, "@") {
+		return p.opts.User
+	}
+	sum := sha256.Sum256([]byte(strings.ToLower(loginHint)))
+	return User{
+		Subject:       "fake-" + hex.EncodeToString(sum[:8]),
+		Email:         loginHint,
+		EmailVerified: p.opts.User.EmailVerified,
+		Name:          strings.SplitN(loginHint, "@", 2)[0],
+	}
+}
+
+// handleToken exchanges an authorization code for an ID token
+func (p *Provider) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, tokenError("invalid_request", "use POST"))
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, tokenError("invalid_request", err.Error()))
+		return
+	}
+
+	clientID, clientSecret, basic := r.BasicAuth()
+	if basic {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.opts.ClientID ||
+		subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.opts.ClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, tokenError("invalid_client", "unknown client or wrong secret"))
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, tokenError("unsupported_grant_type", ""))
+		return
+	}
+
+	// Codes work once, whether or not the exchange succeeds
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	g, ok := p.grants[code]
+	delete(p.grants, code)
+	p.mu.Unlock()
+
+	if !ok || time.Now().After(g.expiresAt) || r.PostForm.Get("redirect_uri") != g.redirectURI {
+		writeJSON(w, http.StatusBadRequest, tokenError("invalid_grant", "unknown or expired code, or redirect_uri mismatch"))
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, tokenError("invalid_grant", "PKCE verification failed"))
+		return
+	}
+
+	idToken, err := p.signIDToken(g)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, tokenError("server_error", err.Error()))
+		return
+	}
+	accessToken, err := randomToken()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, tokenError("server_error", err.Error()))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (p *Provider) signIDToken(g grant) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            p.opts.Issuer,
+		"sub":            g.user.Subject,
+		"aud":            p.opts.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"email":          g.user.Email,
+		"email_verified": g.user.EmailVerified,
+	}
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+	if g.user.Name != "" {
+		claims["name"] = g.user.Name
+	}
+	if g.user.Username != "" {
+		claims["preferred_username"] = g.user.Username
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.keyID
+	return token.SignedString(p.key)
+}
+
+func tokenError(code, description string) map[string]string {
+	return map[string]string{"error": code, "error_description": description}
+}
+
+func randomToken() (string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,254 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ball-knowledge/config"
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+	"ball-knowledge/sessions"
+)
+
+var (
+	// ErrNotConfigured is returned when OIDC login isn't set up
+	ErrNotConfigured = errors.New("OIDC login is not configured")
+	// ErrInvalidState is returned for unknown, expired or already used login states
+	ErrInvalidState = errors.New("invalid or expired OIDC login state")
+)
+
+// Config is the OpenID Connect client configuration
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // Empty for a public client, which relies on PKCE alone
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Identity is the user an identity provider vouched for in an ID token
+type Identity struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// LoadConfig reads the client configuration from OIDC_* environment variables
+func LoadConfig() Config {
+	scopes := config.List("OIDC_SCOPES", []string{"openid", "email", "profile"})
+	hasOpenID := false
+	for _, scope := range scopes {
+		hasOpenID = hasOpenID || scope == "openid"
+	}
+	if !hasOpenID {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	return Config{
+		Issuer:       strings.TrimSuffix(config.String("OIDC_ISSUER", ""), "/"),
+		ClientID:     config.String("OIDC_CLIENT_ID", ""),
+		ClientSecret: config.String("OIDC_CLIENT_SECRET", ""),
+		RedirectURL:  config.String("OIDC_REDIRECT_URL", "http://localhost:8081/api/auth/oidc/callback"),
+		Scopes:       scopes,
+	}
+}
+
+// Enabled reports whether OIDC login is configured
+func Enabled() bool {
+	cfg := LoadConfig()
+	return cfg.Issuer != "" && cfg.ClientID != ""
+}
+
+// ProviderName is shown to users on the login button, e.g. "Sign in with Company SSO"
+func ProviderName() string {
+	return config.String("OIDC_PROVIDER_NAME", "Single sign-on")
+}
+
+// LoginTTL is how long a user has to sign in at the provider after starting a login
+func LoginTTL() time.Duration {
+	return config.Duration("OIDC_LOGIN_TTL", 10*time.Minute)
+}
+
+// Begin starts a login and returns the provider URL to send the user to, and the state
+// that comes back with them. The nonce and PKCE verifier stay on the server. loginHint,
+// if set, suggests the account to sign in with.
+func Begin(ctx context.Context, loginHint string) (authURL, state string, err error) {
+	cfg := LoadConfig()
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		return "", "", ErrNotConfigured
+	}
+
+	metadata, err := discover(ctx, cfg.Issuer)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, stateHash, err := sessions.NewToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	// Logins that were never finished are cleared out as new ones start
+	if err := database.DB.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{}).Error; err != nil {
+		return "", "", err
+	}
+	if err := database.DB.Create(&models.OIDCLoginState{
+		StateHash:    stateHash,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(LoginTTL()),
+	}).Error; err != nil {
+		return "", "", fmt.Errorf("failed to store OIDC login state: %v", err)
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.ClientID},
+		"redirect_uri":          {cfg.RedirectURL},
+		"scope":                 {strings.Join(cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	if loginHint != "" {
+		query.Set("login_hint", loginHint)
+	}
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), state, nil
+}
+
+// Complete finishes a login when the provider redirects back with an authorization code.
+// It exchanges the code for an ID token and returns the identity in it. Each state works once.
+func Complete(ctx context.Context, state, code string) (Identity, error) {
+	cfg := LoadConfig()
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		return Identity{}, ErrNotConfigured
+	}
+
+	var stored models.OIDCLoginState
+	if err := database.DB.Where("state_hash = ?", sessions.HashToken(state)).First(&stored).Error; err != nil {
+		return Identity{}, ErrInvalidState
+	}
+	// Claim the state; a concurrent callback that got there first makes it invalid
+	result := database.DB.Where("id = ?", stored.ID).Delete(&models.OIDCLoginState{})
+	if result.Error != nil {
+		return Identity{}, result.Error
+	}
+	if result.RowsAffected == 0 || time.Now().After(stored.ExpiresAt) {
+		return Identity{}, ErrInvalidState
+	}
+
+	metadata, err := discover(ctx, cfg.Issuer)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	idToken, err := exchangeCode(ctx, cfg, metadata, code, stored.CodeVerifier)
+	if err != nil {
+		return Identity{}, err
+	}
+	return verifyIDToken(ctx, cfg, metadata, idToken, stored.Nonce)
+}
+
+// tokenResponse is the token endpoint response (RFC 6749 section 5)
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func exchangeCode(ctx context.Context, cfg Config, metadata providerMetadata, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if cfg.ClientSecret == "" {
+		form.Set("client_id", cfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.ClientSecret != "" {
+		// client_secret_basic, the default client authentication (RFC 6749 section 2.3.1)
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("error reading token response: %v", err)
+	}
+
+	var tokens tokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return "", fmt.Errorf("token endpoint returned status %d: %s", resp.StatusCode, truncate(body))
+	}
+	if tokens.Error != "" {
+		return "", fmt.Errorf("token endpoint returned %s: %s", tokens.Error, tokens.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+	if tokens.IDToken == "" {
+		return "", errors.New("token response has no id_token; is the openid scope allowed for this client?")
+	}
+	return tokens.IDToken, nil
+}
+
+// codeChallenge is the S256 PKCE challenge for a verifier (RFC 7636 section 4.2)
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomString returns 256 random bits, base64url encoded: a valid PKCE verifier or nonce
+func randomString() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func truncate(body []byte) string {
+	if len(body) > 200 {
+		return string(body[:200]) + "..."
+	}
+	return string(body)
+}
@@ -0,0 +1,219 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"ball-knowledge/auth"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Provider metadata is cached for metadataTTL. Keys are cached until a token names a key
+// we don't have, which is how providers roll their keys over. ID tokens only come from the
+// token endpoint, so clients can't force refetches with made-up key IDs.
+const metadataTTL = time.Hour
+
+// providerMetadata is the part of the discovery document we use (OpenID Connect Discovery 1.0)
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type providerCache struct {
+	mu        sync.Mutex
+	issuer    string
+	metadata  providerMetadata
+	fetchedAt time.Time
+	keys      map[string]interface{}
+}
+
+var cache providerCache
+
+func discover(ctx context.Context, issuer string) (providerMetadata, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.issuer == issuer && time.Since(cache.fetchedAt) < metadataTTL {
+		return cache.metadata, nil
+	}
+
+	var metadata providerMetadata
+	if err := getJSON(ctx, issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return providerMetadata{}, fmt.Errorf("OIDC discovery failed: %v", err)
+	}
+	// The issuer must match exactly, or ID tokens from another provider could be accepted
+	if strings.TrimSuffix(metadata.Issuer, "/") != issuer {
+		return providerMetadata{}, fmt.Errorf("OIDC discovery returned issuer %q, expected %q", metadata.Issuer, issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return providerMetadata{}, errors.New("OIDC discovery document is missing endpoints")
+	}
+
+	if cache.issuer != issuer {
+		cache.keys = nil
+	}
+	cache.issuer = issuer
+	cache.metadata = metadata
+	cache.fetchedAt = time.Now()
+	return metadata, nil
+}
+
+// idTokenClaims are the ID token claims we check and use
+type idTokenClaims struct {
+	Nonce             string `json:"nonce"`
+	AuthorizedParty   string `json:"azp"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	jwt.RegisteredClaims
+}
+
+// verifyIDToken checks an ID token's signature and claims (OpenID Connect Core 3.1.3.7)
+func verifyIDToken(ctx context.Context, cfg Config, metadata providerMetadata, idToken, nonce string) (Identity, error) {
+	claims := &idTokenClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "EdDSA"}))
+	if _, err := parser.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return verificationKey(ctx, metadata, kid, token.Method.Alg())
+	}); err != nil {
+		return Identity{}, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	if strings.TrimSuffix(claims.Issuer, "/") != cfg.Issuer {
+		return Identity{}, fmt.Errorf("invalid ID token: issuer %q", claims.Issuer)
+	}
+	if !claims.VerifyAudience(cfg.ClientID, true) {
+		return Identity{}, errors.New("invalid ID token: not issued to this client")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != cfg.ClientID {
+		return Identity{}, errors.New("invalid ID token: authorized party is not this client")
+	}
+	if claims.ExpiresAt == nil {
+		return Identity{}, errors.New("invalid ID token: no expiry")
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return Identity{}, errors.New("invalid ID token: nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return Identity{}, errors.New("invalid ID token: no subject")
+	}
+
+	return Identity{
+		Issuer:            cfg.Issuer,
+		Subject:           claims.Subject,
+		Email:             strings.TrimSpace(claims.Email),
+		EmailVerified:     claims.EmailVerified,
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+// verificationKey returns the provider key for kid, refetching the provider's keys if it
+// isn't known. The key type must suit alg, so a key can't be used with another algorithm.
+func verificationKey(ctx context.Context, metadata providerMetadata, kid, alg string) (interface{}, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	key, ok := lookupKey(kid)
+	if !ok {
+		keys, err := fetchKeys(ctx, metadata.JWKSURI)
+		if err != nil {
+			return nil, err
+		}
+		cache.keys = keys
+		key, ok = lookupKey(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "RS") {
+			return key, nil
+		}
+	case ed25519.PublicKey:
+		if alg == "EdDSA" {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("signing key %q can't be used with %s", kid, alg)
+}
+
+// lookupKey finds a cached key. Tokens without a kid are accepted when the provider has one key.
+func lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(cache.keys) == 1 {
+		for _, key := range cache.keys {
+			return key, true
+		}
+	}
+	key, ok := cache.keys[kid]
+	return key, ok
+}
+
+// fetchKeys loads the provider's signing keys. Key types other than RSA and Ed25519 are skipped.
+func fetchKeys(ctx context.Context, jwksURI string) (map[string]interface{}, error) {
+	var set auth.JWKSet
+	if err := getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC signing keys: %v", err)
+	}
+
+	keys := make(map[string]interface{})
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		switch jwk.KeyType {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+			if errN != nil || errE != nil || len(e) > 4 {
+				continue
+			}
+			keys[jwk.KeyID] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "OKP":
+			x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+			if err != nil || jwk.Curve != "Ed25519" || len(x) != ed25519.PublicKeySize {
+				continue
+			}
+			keys[jwk.KeyID] = ed25519.PublicKey(x)
+		}
+	}
+	return keys, nil
+}
+
+func getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
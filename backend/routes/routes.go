@@ -3,6 +3,7 @@ package routes
 import (
 	"ball-knowledge/controllers"
 	"ball-knowledge/middleware"
+	"ball-knowledge/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,12 +16,23 @@ func SetupRoutes(router *gin.RouterGroup) {
 		// Authentication routes
 		public.POST("/register", controllers.RegisterUser)
 		public.POST("/login", controllers.LoginUser)
+		public.POST("/login/2fa", controllers.LoginTwoFactor)
+		public.POST("/refresh-token", controllers.RefreshToken)
+		public.POST("/logout", controllers.Logout)
+		public.POST("/forgot-password", controllers.ForgotPassword)
+		public.POST("/reset-password", controllers.ResetPassword)
+		public.POST("/verify-email", controllers.VerifyEmail)
+		public.GET("/auth/oidc", controllers.GetOIDCProvider)
+		public.GET("/auth/oidc/login", controllers.StartOIDCLogin)
+		public.GET("/auth/oidc/callback", controllers.OIDCCallback)
 
 		// Public match data (optional: make these require auth)
 		public.GET("/matches", controllers.GetMatches)
 		public.GET("/matches/:gameweek", controllers.GetMatchesForGameWeek)
 		public.GET("/matches/details/:id", controllers.GetMatchDetails)
 		public.GET("/leaderboard", controllers.GetLeaderboard)
+		public.GET("/leaderboard/history/:userId", controllers.GetRankHistory)
+		public.GET("/scoring/rules", controllers.GetScoringRules)
 	}
 
 	// Protected routes (authentication required)
@@ -29,16 +41,68 @@ func SetupRoutes(router *gin.RouterGroup) {
 	{
 		// User profile
 		protected.GET("/profile", controllers.GetUserProfile)
-		protected.POST("/refresh-token", controllers.RefreshToken)
+		protected.POST("/resend-verification", controllers.ResendVerification)
+
+		// Two-factor authentication
+		protected.GET("/2fa", controllers.GetTwoFactorStatus)
+		protected.POST("/2fa/enroll", controllers.EnrollTwoFactor)
+		protected.POST("/2fa/confirm", controllers.ConfirmTwoFactor)
+		protected.POST("/2fa/disable", controllers.DisableTwoFactor)
+		protected.POST("/2fa/recovery-codes", controllers.RegenerateRecoveryCodes)
 
 		// Predictions
 		protected.POST("/predictions", controllers.CreatePrediction)
 		protected.GET("/predictions/:matchId", controllers.GetPrediction)
 		protected.GET("/my-predictions", controllers.GetUserPredictions)
 		protected.PUT("/predictions/:id", controllers.UpdatePrediction)
+		protected.GET("/leaderboard/me", controllers.GetMyLeaderboardPosition)
+
+		// Private mini-leagues
+		protected.POST("/leagues", controllers.CreateLeague)
+		protected.GET("/leagues", controllers.GetMyLeagues)
+		protected.POST("/leagues/join", controllers.JoinLeague)
+		protected.POST("/leagues/join/:code", controllers.JoinLeague)
+		protected.GET("/leagues/:id", controllers.GetLeague)
+		protected.DELETE("/leagues/:id", controllers.DeleteLeague)
+		protected.GET("/leagues/:id/leaderboard", controllers.GetLeagueLeaderboard)
+		protected.GET("/leagues/:id/leaderboard/me", controllers.GetMyLeagueLeaderboardPosition)
+		protected.POST("/leagues/:id/invite-code", controllers.RegenerateInviteCode)
+		protected.DELETE("/leagues/:id/membership", controllers.LeaveLeague)
+		protected.DELETE("/leagues/:id/members/:userId", controllers.RemoveLeagueMember)
 
-		// Admin-only routes (you can add admin middleware later)
-		protected.POST("/matches", controllers.CreateMatch)
+		// Match management (moderators and admins)
+		moderator := protected.Group("/")
+		moderator.Use(middleware.RequireRole(models.RoleModerator))
+		{
+			moderator.POST("/matches", controllers.CreateMatch)
+			moderator.GET("/admin/sync-runs", controllers.GetSyncRuns)
+			moderator.POST("/admin/sync", controllers.TriggerSync)
+			moderator.GET("/admin/match-changes", controllers.GetMatchChanges)
+			moderator.PUT("/admin/matches/:id", controllers.UpdateMatch)
+			moderator.PUT("/admin/matches/:id/result", controllers.SetMatchResult)
+			moderator.PUT("/admin/matches/:id/status", controllers.UpdateMatchStatus)
+			moderator.POST("/admin/matches/:id/reschedule", controllers.RescheduleMatch)
+			moderator.DELETE("/admin/matches/:id/lock", controllers.UnlockMatch)
+		}
+
+		// Scoring, settlement and user administration (admins only)
+		admin := protected.Group("/admin")
+		admin.Use(middleware.RequireRole(models.RoleAdmin))
+		{
+			admin.POST("/rescore", controllers.RescorePredictions)
+			admin.POST("/leaderboard/rebuild", controllers.RebuildStandings)
+			admin.GET("/scoring/rule-sets", controllers.GetRuleSets)
+			admin.POST("/scoring/rule-sets", controllers.CreateRuleSet)
+			admin.GET("/scoring/competitions", controllers.GetCompetitionScoring)
+			admin.PUT("/scoring/competitions", controllers.AssignRuleSet)
+			admin.GET("/users", controllers.GetUsers)
+			admin.PUT("/users/:id/role", controllers.UpdateUserRole)
+			admin.DELETE("/matches/:id", controllers.DeleteMatch)
+			admin.GET("/audit-log", controllers.GetAuditLog)
+			admin.GET("/security-events", controllers.GetSecurityEvents)
+			admin.DELETE("/users/:id/lockout", controllers.ClearUserLockout)
+			admin.DELETE("/users/:id/2fa", controllers.ResetUserTwoFactor)
+		}
 	}
 
 	// Health check endpoint
@@ -49,4 +113,9 @@ func SetupRoutes(router *gin.RouterGroup) {
 			"version": "1.0.0",
 		})
 	})
-}
\ No newline at end of file
+}
+
+// SetupWellKnownRoutes configures the /.well-known endpoints, which live outside /api
+func SetupWellKnownRoutes(router *gin.Engine) {
+	router.GET("/.well-known/jwks.json", controllers.GetJWKS)
+}
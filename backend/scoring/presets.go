@@ -0,0 +1,51 @@
+package scoring
+
+// DefaultRuleSetName is used for competitions without an assigned rule set
+// unless SCORING_RULE_SET names another one
+const DefaultRuleSetName = "default"
+
+// Presets are the built-in rule sets, saved to the database on startup
+var Presets = []RuleSet{
+	{
+		Name:        DefaultRuleSetName,
+		Version:     1,
+		Description: "Original Ball Knowledge scoring: 10 exact score, 5 outcome, 3 total goals, 2 goal difference, all stacking",
+		Mode:        ModeStack,
+		Rules: []Rule{
+			{Type: RuleExactScore, Points: 10},
+			{Type: RuleOutcome, Points: 5},
+			{Type: RuleTotalGoals, Points: 3},
+			{Type: RuleGoalDifference, Points: 2},
+		},
+	},
+	{
+		Name:        "classic",
+		Version:     1,
+		Description: "3 points for the exact score, otherwise 1 for the right outcome",
+		Mode:        ModeFirst,
+		Rules: []Rule{
+			{Type: RuleExactScore, Points: 3},
+			{Type: RuleOutcome, Points: 1},
+		},
+	},
+	{
+		Name:        "superbru",
+		Version:     1,
+		Description: "Superbru-style: 3 exact, 2 close (right outcome, within one goal), 1 right outcome",
+		Mode:        ModeFirst,
+		Rules: []Rule{
+			{Type: RuleExactScore, Points: 3},
+			{Type: RuleCloseness, Points: 2, MaxGoalDistance: 1},
+			{Type: RuleOutcome, Points: 1},
+		},
+	},
+	{
+		Name:        "exact_only",
+		Version:     1,
+		Description: "Only exact scores count",
+		Mode:        ModeFirst,
+		Rules: []Rule{
+			{Type: RuleExactScore, Points: 3},
+		},
+	},
+}
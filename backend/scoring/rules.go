@@ -0,0 +1,186 @@
+package scoring
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Rule types
+const (
+	RuleExactScore     = "exact_score"     // Predicted score matches exactly
+	RuleOutcome        = "outcome"         // Home win, draw or away win predicted correctly
+	RuleTotalGoals     = "total_goals"     // Total number of goals predicted correctly
+	RuleGoalDifference = "goal_difference" // Goal difference predicted correctly
+	RuleCloseness      = "closeness"       // Outcome correct and each side's goals off by at most MaxGoalDistance in total
+)
+
+// Rule set modes
+const (
+	ModeStack = "stack" // Every matching rule awards its points
+	ModeFirst = "first" // Only the first matching rule, in order, awards its points
+)
+
+// Rule awards points when a prediction meets its condition
+type Rule struct {
+	Type            string `json:"type" yaml:"type"`
+	Points          int    `json:"points" yaml:"points"`
+	MaxGoalDistance int    `json:"max_goal_distance,omitempty" yaml:"max_goal_distance,omitempty"` // closeness only
+}
+
+// Multiplier scales the points of matches on the listed gameweeks, e.g. a double-points final day
+type Multiplier struct {
+	Name      string `json:"name" yaml:"name"`
+	MatchDays []int  `json:"match_days" yaml:"match_days"`
+	Factor    int    `json:"factor" yaml:"factor"`
+}
+
+// RuleSet is a complete scoring system. Rule sets are data: they are stored
+// as JSON, can be loaded from JSON or YAML files and are immutable once
+// saved; changing one means saving a new version.
+type RuleSet struct {
+	Name        string       `json:"name" yaml:"name"`
+	Version     int          `json:"version" yaml:"version"`
+	Description string       `json:"description,omitempty" yaml:"description,omitempty"`
+	Mode        string       `json:"mode" yaml:"mode"`
+	Rules       []Rule       `json:"rules" yaml:"rules"`
+	Multipliers []Multiplier `json:"multipliers,omitempty" yaml:"multipliers,omitempty"`
+}
+
+// Award is the points a single rule contributed to a prediction
+type Award struct {
+	Rule   string `json:"rule"`
+	Points int    `json:"points"`
+}
+
+// AppliedMultiplier is a multiplier that scaled a prediction's points
+type AppliedMultiplier struct {
+	Name   string `json:"name"`
+	Factor int    `json:"factor"`
+	Bonus  int    `json:"bonus"` // Points added on top of the rule awards
+}
+
+// Result is a scored prediction: the total and the awards that make it up
+type Result struct {
+	Points      int                 `json:"points"`
+	Awards      []Award             `json:"awards"`
+	Multipliers []AppliedMultiplier `json:"multipliers"`
+}
+
+// Validate checks that the rule set can be evaluated
+func (rs RuleSet) Validate() error {
+	if rs.Name == "" {
+		return errors.New("rule set name is required")
+	}
+	if rs.Mode != ModeStack && rs.Mode != ModeFirst {
+		return fmt.Errorf("mode must be %q or %q", ModeStack, ModeFirst)
+	}
+	if len(rs.Rules) == 0 {
+		return errors.New("rule set needs at least one rule")
+	}
+
+	for i, rule := range rs.Rules {
+		switch rule.Type {
+		case RuleExactScore, RuleOutcome, RuleTotalGoals, RuleGoalDifference:
+		case RuleCloseness:
+			if rule.MaxGoalDistance < 1 {
+				return fmt.Errorf("rule %d: closeness needs max_goal_distance of at least 1", i+1)
+			}
+		default:
+			return fmt.Errorf("rule %d: unknown rule type %q", i+1, rule.Type)
+		}
+		if rule.Points < 0 {
+			return fmt.Errorf("rule %d: points cannot be negative", i+1)
+		}
+	}
+
+	for _, multiplier := range rs.Multipliers {
+		if multiplier.Factor < 1 {
+			return fmt.Errorf("multiplier %q: factor must be at least 1", multiplier.Name)
+		}
+	}
+
+	return nil
+}
+
+// Score evaluates a prediction against an actual score
+func (rs RuleSet) Score(predictedHome, predictedAway, actualHome, actualAway, matchDay int) Result {
+	result := emptyResult()
+
+	for _, rule := range rs.Rules {
+		if !rule.matches(predictedHome, predictedAway, actualHome, actualAway) {
+			continue
+		}
+
+		result.Points += rule.Points
+		result.Awards = append(result.Awards, Award{Rule: rule.Type, Points: rule.Points})
+
+		if rs.Mode == ModeFirst {
+			break
+		}
+	}
+
+	for _, multiplier := range rs.Multipliers {
+		if result.Points == 0 || !containsInt(multiplier.MatchDays, matchDay) {
+			continue
+		}
+
+		bonus := result.Points * (multiplier.Factor - 1)
+		result.Points += bonus
+		result.Multipliers = append(result.Multipliers, AppliedMultiplier{
+			Name:   multiplier.Name,
+			Factor: multiplier.Factor,
+			Bonus:  bonus,
+		})
+	}
+
+	return result
+}
+
+func emptyResult() Result {
+	return Result{Awards: []Award{}, Multipliers: []AppliedMultiplier{}}
+}
+
+// matches reports whether a prediction satisfies the rule
+func (rule Rule) matches(predictedHome, predictedAway, actualHome, actualAway int) bool {
+	switch rule.Type {
+	case RuleExactScore:
+		return predictedHome == actualHome && predictedAway == actualAway
+	case RuleOutcome:
+		return getMatchResult(predictedHome, predictedAway) == getMatchResult(actualHome, actualAway)
+	case RuleTotalGoals:
+		return predictedHome+predictedAway == actualHome+actualAway
+	case RuleGoalDifference:
+		return predictedHome-predictedAway == actualHome-actualAway
+	case RuleCloseness:
+		distance := abs(predictedHome-actualHome) + abs(predictedAway-actualAway)
+		return distance <= rule.MaxGoalDistance &&
+			getMatchResult(predictedHome, predictedAway) == getMatchResult(actualHome, actualAway)
+	}
+	return false
+}
+
+// getMatchResult determines the match result (home win, away win, or draw)
+func getMatchResult(homeScore, awayScore int) string {
+	if homeScore > awayScore {
+		return "home_win"
+	} else if awayScore > homeScore {
+		return "away_win"
+	}
+	return "draw"
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func containsInt(values []int, n int) bool {
+	for _, v := range values {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package scoring
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type scoreCase struct {
+	name                         string
+	predictedHome, predictedAway int
+	actualHome, actualAway       int
+	matchDay                     int
+	points                       int
+	awards                       string // rule:points in the order the rules awarded them
+	multipliers                  string // name xfactor +bonus
+}
+
+func TestPresetScoring(t *testing.T) {
+	tests := map[string][]scoreCase{
+		"default": {
+			{name: "exact score", predictedHome: 2, predictedAway: 1, actualHome: 2, actualAway: 1,
+				points: 20, awards: "exact_score:10 outcome:5 total_goals:3 goal_difference:2"},
+			{name: "outcome and goal difference", predictedHome: 1, predictedAway: 0, actualHome: 2, actualAway: 1,
+				points: 7, awards: "outcome:5 goal_difference:2"},
+			{name: "outcome only", predictedHome: 3, predictedAway: 1, actualHome: 2, actualAway: 1,
+				points: 5, awards: "outcome:5"},
+			{name: "draw with other goals", predictedHome: 1, predictedAway: 1, actualHome: 0, actualAway: 0,
+				points: 7, awards: "outcome:5 goal_difference:2"},
+			{name: "wrong outcome, right total goals", predictedHome: 0, predictedAway: 2, actualHome: 2, actualAway: 0,
+				points: 3, awards: "total_goals:3"},
+			{name: "wrong result", predictedHome: 1, predictedAway: 1, actualHome: 2, actualAway: 1,
+				points: 0, awards: ""},
+		},
+		"classic": {
+			{name: "exact score", predictedHome: 2, predictedAway: 1, actualHome: 2, actualAway: 1,
+				points: 3, awards: "exact_score:3"},
+			{name: "outcome only", predictedHome: 3, predictedAway: 1, actualHome: 2, actualAway: 1,
+				points: 1, awards: "outcome:1"},
+			{name: "draw with other goals", predictedHome: 2, predictedAway: 2, actualHome: 0, actualAway: 0,
+				points: 1, awards: "outcome:1"},
+			{name: "wrong result", predictedHome: 1, predictedAway: 2, actualHome: 2, actualAway: 1,
+				points: 0, awards: ""},
+		},
+		"superbru": {
+			{name: "exact score", predictedHome: 2, predictedAway: 1, actualHome: 2, actualAway: 1,
+				points: 3, awards: "exact_score:3"},
+			{name: "close", predictedHome: 2, predictedAway: 0, actualHome: 2, actualAway: 1,
+				points: 2, awards: "closeness:2"},
+			{name: "outcome only", predictedHome: 3, predictedAway: 0, actualHome: 1, actualAway: 0,
+				points: 1, awards: "outcome:1"},
+			{name: "close goals, wrong outcome", predictedHome: 1, predictedAway: 1, actualHome: 2, actualAway: 1,
+				points: 0, awards: ""},
+			{name: "wrong result", predictedHome: 0, predictedAway: 3, actualHome: 2, actualAway: 1,
+				points: 0, awards: ""},
+		},
+		"exact_only": {
+			{name: "exact score", predictedHome: 0, predictedAway: 0, actualHome: 0, actualAway: 0,
+				points: 3, awards: "exact_score:3"},
+			{name: "outcome only", predictedHome: 3, predictedAway: 1, actualHome: 2, actualAway: 1,
+				points: 0, awards: ""},
+			{name: "wrong result", predictedHome: 1, predictedAway: 2, actualHome: 2, actualAway: 1,
+				points: 0, awards: ""},
+		},
+	}
+
+	for name, cases := range tests {
+		ruleSet := preset(t, name)
+		for _, tc := range cases {
+			t.Run(name+"/"+tc.name, func(t *testing.T) {
+				assertScore(t, ruleSet, tc)
+			})
+		}
+	}
+}
+
+func TestMultipliers(t *testing.T) {
+	finalDay := Multiplier{Name: "Final day", MatchDays: []int{38}, Factor: 2}
+
+	stacked := preset(t, "default")
+	stacked.Multipliers = []Multiplier{finalDay}
+	first := preset(t, "classic")
+	first.Multipliers = []Multiplier{finalDay, {Name: "Derby", MatchDays: []int{20, 38}, Factor: 3}}
+
+	tests := []struct {
+		ruleSet RuleSet
+		scoreCase
+	}{
+		{stacked, scoreCase{name: "default on the final day", predictedHome: 2, predictedAway: 1, actualHome: 2, actualAway: 1, matchDay: 38,
+			points: 40, awards: "exact_score:10 outcome:5 total_goals:3 goal_difference:2", multipliers: "Final day x2 +20"}},
+		{stacked, scoreCase{name: "default on another day", predictedHome: 2, predictedAway: 1, actualHome: 2, actualAway: 1, matchDay: 37,
+			points: 20, awards: "exact_score:10 outcome:5 total_goals:3 goal_difference:2"}},
+		{stacked, scoreCase{name: "default wrong result on the final day", predictedHome: 1, predictedAway: 1, actualHome: 2, actualAway: 1, matchDay: 38,
+			points: 0, awards: ""}},
+		{first, scoreCase{name: "classic with both multipliers", predictedHome: 3, predictedAway: 1, actualHome: 2, actualAway: 1, matchDay: 38,
+			points: 6, awards: "outcome:1", multipliers: "Final day x2 +1 Derby x3 +4"}},
+		{first, scoreCase{name: "classic with one multiplier", predictedHome: 2, predictedAway: 1, actualHome: 2, actualAway: 1, matchDay: 20,
+			points: 9, awards: "exact_score:3", multipliers: "Derby x3 +6"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assertScore(t, tc.ruleSet, tc.scoreCase)
+		})
+	}
+}
+
+func TestBreakdownSplitsPointsByRule(t *testing.T) {
+	ruleSet := preset(t, "default")
+	ruleSet.Multipliers = []Multiplier{{Name: "Final day", MatchDays: []int{38}, Factor: 2}}
+
+	breakdown := ruleSet.Score(2, 1, 2, 1, 38).Breakdown(ruleSet)
+	if breakdown.ExactScore != 10 || breakdown.Outcome != 5 || breakdown.TotalGoals != 3 ||
+		breakdown.GoalDifference != 2 || breakdown.Closeness != 0 {
+		t.Errorf("unexpected rule points: %+v", breakdown)
+	}
+	if breakdown.Total != 40 || len(breakdown.Multipliers) != 1 || breakdown.Multipliers[0].Points != 20 {
+		t.Errorf("unexpected total or multipliers: %+v", breakdown)
+	}
+	if breakdown.RuleSet != "default" || breakdown.RuleSetVersion != 1 {
+		t.Errorf("breakdown names rule set %s v%d", breakdown.RuleSet, breakdown.RuleSetVersion)
+	}
+}
+
+func TestPresetsAreValid(t *testing.T) {
+	for _, ruleSet := range Presets {
+		if err := ruleSet.Validate(); err != nil {
+			t.Errorf("%s: %v", ruleSet.Name, err)
+		}
+	}
+}
+
+func preset(t *testing.T, name string) RuleSet {
+	t.Helper()
+	for _, ruleSet := range Presets {
+		if ruleSet.Name == name {
+			return ruleSet
+		}
+	}
+	t.Fatalf("no preset named %s", name)
+	return RuleSet{}
+}
+
+func assertScore(t *testing.T, ruleSet RuleSet, tc scoreCase) {
+	t.Helper()
+	result := ruleSet.Score(tc.predictedHome, tc.predictedAway, tc.actualHome, tc.actualAway, tc.matchDay)
+
+	awards := make([]string, len(result.Awards))
+	for i, award := range result.Awards {
+		awards[i] = fmt.Sprintf("%s:%d", award.Rule, award.Points)
+	}
+	multipliers := make([]string, len(result.Multipliers))
+	for i, multiplier := range result.Multipliers {
+		multipliers[i] = fmt.Sprintf("%s x%d +%d", multiplier.Name, multiplier.Factor, multiplier.Bonus)
+	}
+
+	if result.Points != tc.points {
+		t.Errorf("%d:%d for %d:%d scored %d points, want %d",
+			tc.predictedHome, tc.predictedAway, tc.actualHome, tc.actualAway, result.Points, tc.points)
+	}
+	if got := strings.Join(awards, " "); got != tc.awards {
+		t.Errorf("awards: got %q, want %q", got, tc.awards)
+	}
+	if got := strings.Join(multipliers, " "); got != tc.multipliers {
+		t.Errorf("multipliers: got %q, want %q", got, tc.multipliers)
+	}
+}
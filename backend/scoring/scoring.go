@@ -0,0 +1,91 @@
+package scoring
+
+import (
+	"strconv"
+	"strings"
+
+	"ball-knowledge/models"
+)
+
+// ScorePrediction scores a prediction on a match with the given rule set.
+// Only finished matches with a valid result score.
+func ScorePrediction(ruleSet RuleSet, match models.Match, predictedHome, predictedAway int) Result {
+	if match.Status != models.MatchStatusFinished {
+		return emptyResult()
+	}
+
+	actualHome, actualAway, ok := ParseResult(match.Result)
+	if !ok {
+		return emptyResult()
+	}
+
+	return ruleSet.Score(predictedHome, predictedAway, actualHome, actualAway, match.MatchDay)
+}
+
+// Accuracy reports whether a prediction got a finished match's exact score and its
+// outcome right. Unlike points it doesn't depend on the rule set, which makes it
+// suitable for comparing users across competitions, e.g. as a leaderboard tie-breaker.
+func Accuracy(match models.Match, predictedHome, predictedAway int) (exactScore, correctOutcome bool) {
+	if match.Status != models.MatchStatusFinished {
+		return false, false
+	}
+
+	actualHome, actualAway, ok := ParseResult(match.Result)
+	if !ok {
+		return false, false
+	}
+
+	exactScore = predictedHome == actualHome && predictedAway == actualAway
+	correctOutcome = getMatchResult(predictedHome, predictedAway) == getMatchResult(actualHome, actualAway)
+	return exactScore, correctOutcome
+}
+
+// Breakdown converts a result into the per-component breakdown stored on a prediction
+func (r Result) Breakdown(ruleSet RuleSet) *models.PointsBreakdown {
+	breakdown := &models.PointsBreakdown{
+		RuleSet:        ruleSet.Name,
+		RuleSetVersion: ruleSet.Version,
+		Multipliers:    []models.BreakdownMultiplier{},
+		Total:          r.Points,
+	}
+
+	for _, award := range r.Awards {
+		switch award.Rule {
+		case RuleOutcome:
+			breakdown.Outcome += award.Points
+		case RuleExactScore:
+			breakdown.ExactScore += award.Points
+		case RuleTotalGoals:
+			breakdown.TotalGoals += award.Points
+		case RuleGoalDifference:
+			breakdown.GoalDifference += award.Points
+		case RuleCloseness:
+			breakdown.Closeness += award.Points
+		}
+	}
+
+	for _, multiplier := range r.Multipliers {
+		breakdown.Multipliers = append(breakdown.Multipliers, models.BreakdownMultiplier{
+			Name:   multiplier.Name,
+			Factor: multiplier.Factor,
+			Points: multiplier.Bonus,
+		})
+	}
+
+	return breakdown
+}
+
+// ParseResult parses a full-time score in "home:away" format
+func ParseResult(result string) (home, away int, ok bool) {
+	parts := strings.Split(result, ":")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	home, err1 := strconv.Atoi(parts[0])
+	away, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return home, away, true
+}
@@ -0,0 +1,223 @@
+package scoring
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"ball-knowledge/config"
+	"ball-knowledge/models"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// ErrRuleSetNotFound is returned when a named rule set or version isn't stored
+var ErrRuleSetNotFound = errors.New("scoring rule set not found")
+
+// Seed stores the built-in presets and every rule set file in dir that isn't stored yet.
+// Stored versions are immutable, so a file that redefines an existing version is skipped with a warning.
+func Seed(db *gorm.DB, dir string) error {
+	ruleSets := append([]RuleSet{}, Presets...)
+
+	if dir != "" {
+		paths, err := filepath.Glob(filepath.Join(dir, "*"))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".json", ".yaml", ".yml":
+			default:
+				continue
+			}
+
+			ruleSet, err := LoadFile(path)
+			if err != nil {
+				return err
+			}
+			ruleSets = append(ruleSets, ruleSet)
+		}
+	}
+
+	for _, ruleSet := range ruleSets {
+		if err := seedRuleSet(db, ruleSet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func seedRuleSet(db *gorm.DB, ruleSet RuleSet) error {
+	if ruleSet.Version < 1 {
+		return fmt.Errorf("rule set %q: version must be at least 1", ruleSet.Name)
+	}
+
+	stored, _, err := Lookup(db, ruleSet.Name, ruleSet.Version)
+	if err == nil {
+		if !reflect.DeepEqual(stored, ruleSet) {
+			log.Printf("⚠️  Rule set %s v%d already stored with a different definition; save it as a new version",
+				ruleSet.Name, ruleSet.Version)
+		}
+		return nil
+	}
+	if !errors.Is(err, ErrRuleSetNotFound) {
+		return err
+	}
+
+	_, err = store(db, ruleSet)
+	return err
+}
+
+// LoadFile parses a rule set from a JSON or YAML file
+func LoadFile(path string) (RuleSet, error) {
+	var ruleSet RuleSet
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ruleSet, fmt.Errorf("error reading rule set file: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &ruleSet)
+	default:
+		err = json.Unmarshal(data, &ruleSet)
+	}
+	if err != nil {
+		return ruleSet, fmt.Errorf("error parsing rule set %s: %v", path, err)
+	}
+
+	if err := ruleSet.Validate(); err != nil {
+		return ruleSet, fmt.Errorf("invalid rule set %s: %v", path, err)
+	}
+	return ruleSet, nil
+}
+
+// SaveNewVersion stores ruleSet as the next version of its name
+func SaveNewVersion(db *gorm.DB, ruleSet RuleSet) (models.ScoringRuleSet, error) {
+	var stored models.ScoringRuleSet
+
+	if err := ruleSet.Validate(); err != nil {
+		return stored, err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&models.ScoringRuleSet{}).
+			Where("name = ?", ruleSet.Name).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&latest).Error; err != nil {
+			return err
+		}
+
+		ruleSet.Version = latest + 1
+		var err error
+		stored, err = store(tx, ruleSet)
+		return err
+	})
+
+	return stored, err
+}
+
+func store(db *gorm.DB, ruleSet RuleSet) (models.ScoringRuleSet, error) {
+	definition, err := json.Marshal(ruleSet)
+	if err != nil {
+		return models.ScoringRuleSet{}, err
+	}
+
+	stored := models.ScoringRuleSet{
+		Name:       ruleSet.Name,
+		Version:    ruleSet.Version,
+		Definition: string(definition),
+	}
+	if err := db.Create(&stored).Error; err != nil {
+		return stored, fmt.Errorf("failed to save rule set %s v%d: %v", ruleSet.Name, ruleSet.Version, err)
+	}
+	return stored, nil
+}
+
+// Lookup loads a stored rule set. Version 0 means the latest version.
+func Lookup(db *gorm.DB, name string, version int) (RuleSet, uuid.UUID, error) {
+	var stored models.ScoringRuleSet
+
+	query := db.Where("name = ?", name)
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	}
+
+	err := query.Order("version DESC").First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return RuleSet{}, uuid.Nil, ErrRuleSetNotFound
+	}
+	if err != nil {
+		return RuleSet{}, uuid.Nil, err
+	}
+
+	ruleSet, err := Decode(stored)
+	return ruleSet, stored.ID, err
+}
+
+// Decode parses a stored rule set's definition
+func Decode(stored models.ScoringRuleSet) (RuleSet, error) {
+	var ruleSet RuleSet
+	if err := json.Unmarshal([]byte(stored.Definition), &ruleSet); err != nil {
+		return ruleSet, fmt.Errorf("corrupt rule set %s v%d: %v", stored.Name, stored.Version, err)
+	}
+	return ruleSet, nil
+}
+
+// ForCompetition returns the rule set that applies to a league and season.
+// A season-specific assignment wins over a league-wide one; without either
+// the rule set named by SCORING_RULE_SET is used.
+func ForCompetition(db *gorm.DB, league, season string) (RuleSet, uuid.UUID, error) {
+	var assignment models.CompetitionScoring
+	err := db.Where("league = ? AND season IN ?", league, []string{season, ""}).
+		Order("season DESC"). // "" sorts last
+		First(&assignment).Error
+
+	switch {
+	case err == nil:
+		return Lookup(db, assignment.RuleSetName, assignment.RuleSetVersion)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return Lookup(db, config.String("SCORING_RULE_SET", DefaultRuleSetName), 0)
+	default:
+		return RuleSet{}, uuid.Nil, err
+	}
+}
+
+// FollowingLatest returns the assignments that follow the latest version of the named rule set.
+// unassigned reports whether it's the rule set used by competitions without an assignment,
+// which then follow it too.
+func FollowingLatest(db *gorm.DB, name string) (assignments []models.CompetitionScoring, unassigned bool, err error) {
+	err = db.Where("rule_set_name = ? AND rule_set_version = ?", name, 0).
+		Order("league ASC, season ASC").
+		Find(&assignments).Error
+	return assignments, name == config.String("SCORING_RULE_SET", DefaultRuleSetName), err
+}
+
+// Assign selects the rule set for a league, or for one season of it when season is set
+func Assign(db *gorm.DB, league, season, name string, version int) (models.CompetitionScoring, error) {
+	var assignment models.CompetitionScoring
+
+	if _, _, err := Lookup(db, name, version); err != nil {
+		return assignment, err
+	}
+
+	err := db.Where("league = ? AND season = ?", league, season).First(&assignment).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return assignment, err
+	}
+
+	assignment.League = league
+	assignment.Season = season
+	assignment.RuleSetName = name
+	assignment.RuleSetVersion = version
+	return assignment, db.Save(&assignment).Error
+}
@@ -0,0 +1,47 @@
+package security
+
+import (
+	"encoding/json"
+	"log"
+
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+
+	"github.com/google/uuid"
+)
+
+// Security event types
+const (
+	EventLoginFailed       = "login.failed"           // Wrong password or unknown account
+	EventLoginThrottled    = "login.throttled"        // Attempt rejected by backoff or lockout
+	EventAccountLocked     = "login.locked"           // An account or IP reached the lockout threshold
+	EventLockoutCleared    = "login.unlocked"         // An admin cleared an account's lockout
+	EventPasswordReset     = "password.reset"         // A password was reset with a reset link
+	EventRefreshTokenReuse = "refresh_token.reuse"    // A used refresh token was presented again
+	EventTwoFactorEnabled  = "2fa.enabled"            // A user confirmed two-factor enrolment
+	EventTwoFactorDisabled = "2fa.disabled"           // Two-factor authentication was turned off, by the user or an admin
+	EventTwoFactorFailed   = "2fa.failed"             // A wrong TOTP or recovery code was entered
+	EventRecoveryCodeUsed  = "2fa.recovery_code_used" // A recovery code was used in place of a TOTP code
+	EventIdentityLinked    = "oidc.linked"            // An OIDC identity was linked to an existing account by email
+)
+
+// RecordEvent stores a security event. Failures are logged rather than returned, so
+// recording never blocks the action itself. details is stored as JSON and may be nil.
+func RecordEvent(eventType string, userID *uuid.UUID, ip string, details interface{}) {
+	event := models.SecurityEvent{
+		Type:      eventType,
+		UserID:    userID,
+		IPAddress: ip,
+	}
+	if details != nil {
+		encoded, err := json.Marshal(details)
+		if err != nil {
+			log.Printf("⚠️  Failed to encode security event details: %v", err)
+		}
+		event.Details = encoded
+	}
+
+	if err := database.DB.Create(&event).Error; err != nil {
+		log.Printf("⚠️  Failed to record security event %s: %v", eventType, err)
+	}
+}
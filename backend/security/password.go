@@ -0,0 +1,17 @@
+package security
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordCost is the bcrypt cost of stored password hashes
+const PasswordCost = 12
+
+// dummyHash is a bcrypt hash, at PasswordCost, of a random password nobody knows
+var dummyHash = []byte("$2a$12$fS1zAOpcDRev9Twzku3hOeyUl1F47AWOnTM3X5v8T54qmJi/j3lW2")
+
+// CompareDummyPassword spends as long as checking a real password, for logins to unknown
+// accounts, so response times don't reveal which accounts exist. It always fails.
+func CompareDummyPassword(password string) {
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
@@ -0,0 +1,199 @@
+package security
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"ball-knowledge/config"
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+
+	"gorm.io/gorm"
+)
+
+// limits controls how failed logins against one key are slowed down and locked out
+type limits struct {
+	backoffAfter int // Failures allowed before each attempt must wait
+	lockoutAfter int // Failures that lock the key for LOGIN_LOCKOUT_DURATION
+}
+
+// Failed logins are tracked per account and per client IP. An IP sees failures across
+// many accounts, so it gets more room before backing off.
+func accountLimits() limits {
+	return limits{
+		backoffAfter: config.Int("LOGIN_BACKOFF_AFTER", 3),
+		lockoutAfter: config.Int("LOGIN_LOCKOUT_AFTER", 10),
+	}
+}
+
+func ipLimits() limits {
+	return limits{
+		backoffAfter: config.Int("LOGIN_IP_BACKOFF_AFTER", 10),
+		lockoutAfter: config.Int("LOGIN_IP_LOCKOUT_AFTER", 50),
+	}
+}
+
+// Every password reset request sends an email, so requests are throttled per client IP
+// like failed logins, to stop anyone mail bombing addresses through the public endpoint
+func passwordResetLimits() limits {
+	return limits{
+		backoffAfter: config.Int("PASSWORD_RESET_IP_BACKOFF_AFTER", 5),
+		lockoutAfter: config.Int("PASSWORD_RESET_IP_LOCKOUT_AFTER", 20),
+	}
+}
+
+// LoginKeys identify the throttles a login attempt counts against
+type LoginKeys struct {
+	Account string
+	IP      string
+}
+
+// NewLoginKeys returns the throttle keys for a login. Unknown accounts are keyed by the
+// identifier that was tried, so they back off and lock exactly like real ones.
+func NewLoginKeys(userID, identifier, ip string) LoginKeys {
+	account := userID
+	if account == "" {
+		account = strings.ToLower(strings.TrimSpace(identifier))
+	}
+	return LoginKeys{Account: "account:" + account, IP: "ip:" + ip}
+}
+
+// AccountKey is the throttle key of a registered account
+func AccountKey(userID string) string {
+	return "account:" + userID
+}
+
+// CheckLogin returns how long the client must wait before trying these keys again,
+// or zero if it may try now
+func CheckLogin(keys LoginKeys) (time.Duration, error) {
+	accountWait, err := wait(keys.Account, accountLimits())
+	if err != nil {
+		return 0, err
+	}
+	ipWait, err := wait(keys.IP, ipLimits())
+	if err != nil {
+		return 0, err
+	}
+
+	if ipWait > accountWait {
+		return ipWait, nil
+	}
+	return accountWait, nil
+}
+
+// LoginFailed counts a failed login against both keys and reports whether it locked either.
+// Each failure past the backoff threshold doubles the wait before the next attempt.
+func LoginFailed(keys LoginKeys) (bool, error) {
+	accountLocked, err := fail(keys.Account, accountLimits())
+	if err != nil {
+		return false, err
+	}
+	ipLocked, err := fail(keys.IP, ipLimits())
+	if err != nil {
+		return false, err
+	}
+	return accountLocked || ipLocked, nil
+}
+
+// LoginSucceeded clears an account's failures. The IP's failures are kept, so an attacker
+// can't reset them by logging in to an account of their own between guesses.
+func LoginSucceeded(keys LoginKeys) error {
+	return ClearLockout(keys.Account)
+}
+
+// ClearLockout forgets the failures and lockout of a key
+func ClearLockout(key string) error {
+	return database.DB.Where("key = ?", key).Delete(&models.LoginThrottle{}).Error
+}
+
+// PasswordResetKey is the throttle key of password reset requests from a client IP
+func PasswordResetKey(ip string) string {
+	return "reset:ip:" + ip
+}
+
+// CheckPasswordReset returns how long the client at ip must wait before requesting
+// another password reset, or zero if it may request one now
+func CheckPasswordReset(ip string) (time.Duration, error) {
+	return wait(PasswordResetKey(ip), passwordResetLimits())
+}
+
+// PasswordResetRequested counts a password reset request against the client's IP
+func PasswordResetRequested(ip string) error {
+	_, err := fail(PasswordResetKey(ip), passwordResetLimits())
+	return err
+}
+
+func wait(key string, l limits) (time.Duration, error) {
+	var throttle models.LoginThrottle
+	err := database.DB.Where("key = ?", key).First(&throttle).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+		return throttle.LockedUntil.Sub(now), nil
+	}
+	if throttle.Failures < l.backoffAfter || now.Sub(throttle.LastFailureAt) > failureWindow() {
+		return 0, nil
+	}
+
+	next := throttle.LastFailureAt.Add(backoff(throttle.Failures - l.backoffAfter))
+	if next.After(now) {
+		return next.Sub(now), nil
+	}
+	return 0, nil
+}
+
+func fail(key string, l limits) (bool, error) {
+	locked := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		throttle := models.LoginThrottle{Key: key}
+		if err := tx.Where("key = ?", key).First(&throttle).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// Failures age out, and a lockout that has ended starts the count over
+		now := time.Now()
+		if now.Sub(throttle.LastFailureAt) > failureWindow() ||
+			(throttle.LockedUntil != nil && !throttle.LockedUntil.After(now)) {
+			throttle.Failures = 0
+			throttle.LockedUntil = nil
+		}
+
+		throttle.Failures++
+		throttle.LastFailureAt = now
+		if throttle.Failures >= l.lockoutAfter && throttle.LockedUntil == nil {
+			until := now.Add(config.Duration("LOGIN_LOCKOUT_DURATION", 15*time.Minute))
+			throttle.LockedUntil = &until
+			locked = true
+		}
+		return tx.Save(&throttle).Error
+	})
+	return locked, err
+}
+
+// backoff is the wait after the nth failure past the backoff threshold:
+// LOGIN_BACKOFF_BASE doubled n times, up to LOGIN_BACKOFF_MAX
+func backoff(n int) time.Duration {
+	base := config.Duration("LOGIN_BACKOFF_BASE", time.Second)
+	max := config.Duration("LOGIN_BACKOFF_MAX", 5*time.Minute)
+
+	d := base
+	for i := 0; i < n && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// failureWindow is how long a failed login counts towards backoff and lockout
+func failureWindow() time.Duration {
+	return config.Duration("LOGIN_FAILURE_WINDOW", time.Hour)
+}
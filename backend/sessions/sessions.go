@@ -0,0 +1,169 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"ball-knowledge/config"
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+	"ball-knowledge/security"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidToken is returned for unknown, expired or revoked refresh tokens
+	ErrInvalidToken = errors.New("invalid refresh token")
+	// ErrTokenReused is returned when an already used refresh token is presented again.
+	// Its whole family has been revoked.
+	ErrTokenReused = errors.New("refresh token reused")
+)
+
+// Client identifies where a refresh token was issued, for listing and auditing sessions
+type Client struct {
+	UserAgent string
+	IPAddress string
+}
+
+// RefreshTokenTTL is how long a refresh token can be exchanged for a new one
+func RefreshTokenTTL() time.Duration {
+	return config.Duration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+// Issue starts a new token family for a user, e.g. on login, and returns its first refresh token.
+// mfa records whether the login passed two-factor authentication; rotated tokens keep it.
+func Issue(userID uuid.UUID, client Client, mfa bool) (string, error) {
+	return issue(database.DB, userID, uuid.New(), client, mfa)
+}
+
+// Rotate exchanges a refresh token for a new one in the same family. Each token can be used
+// once: presenting a used token again revokes the family, since either the client or an
+// attacker holds a stolen copy. It returns the new token and the record of the one it replaced.
+func Rotate(token string, client Client) (string, models.RefreshToken, error) {
+	var next string
+	var stored models.RefreshToken
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ?", HashToken(token)).First(&stored).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+
+		if stored.UsedAt != nil {
+			return ErrTokenReused
+		}
+		if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+			return ErrInvalidToken
+		}
+
+		// Claim the token; a concurrent rotation that got there first makes this a reuse
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenReused
+		}
+
+		var err error
+		next, err = issue(tx, stored.UserID, stored.FamilyID, client, stored.MFA)
+		return err
+	})
+
+	if errors.Is(err, ErrTokenReused) {
+		log.Printf("🚨 Refresh token reuse detected for user %s, revoking token family %s", stored.UserID, stored.FamilyID)
+		security.RecordEvent(security.EventRefreshTokenReuse, &stored.UserID, client.IPAddress, map[string]interface{}{
+			"family_id": stored.FamilyID,
+		})
+		if revokeErr := revokeFamily(database.DB, stored.FamilyID); revokeErr != nil {
+			return "", models.RefreshToken{}, fmt.Errorf("failed to revoke token family: %v", revokeErr)
+		}
+	}
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+	return next, stored, nil
+}
+
+// Revoke revokes the family of a refresh token, ending that login. It returns the
+// token's user, or ErrInvalidToken if the token is unknown.
+func Revoke(token string) (uuid.UUID, error) {
+	var stored models.RefreshToken
+	if err := database.DB.Where("token_hash = ?", HashToken(token)).First(&stored).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, ErrInvalidToken
+		}
+		return uuid.Nil, err
+	}
+	return stored.UserID, revokeFamily(database.DB, stored.FamilyID)
+}
+
+// RevokeAll revokes every refresh token of a user, ending all their logins
+func RevokeAll(tx *gorm.DB, userID uuid.UUID) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// PruneExpired deletes refresh tokens that expired more than a day ago. Recently expired
+// tokens are kept so that reuse of a just-rotated token is still detected.
+func PruneExpired() (int64, error) {
+	result := database.DB.Where("expires_at < ?", time.Now().Add(-24*time.Hour)).Delete(&models.RefreshToken{})
+	return result.RowsAffected, result.Error
+}
+
+func issue(tx *gorm.DB, userID, familyID uuid.UUID, client Client, mfa bool) (string, error) {
+	token, hash, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	stored := models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(RefreshTokenTTL()),
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+		MFA:       mfa,
+	}
+	if err := tx.Create(&stored).Error; err != nil {
+		return "", fmt.Errorf("failed to store refresh token: %v", err)
+	}
+	return token, nil
+}
+
+func revokeFamily(tx *gorm.DB, familyID uuid.UUID) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// NewToken generates a random opaque token and the hash to store in its place
+func NewToken() (token, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %v", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(raw)
+	return token, HashToken(token), nil
+}
+
+// HashToken hashes an opaque token for storage. Tokens are random, so a fast unsalted
+// hash is enough to make a leaked database useless for using them.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
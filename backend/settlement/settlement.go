@@ -0,0 +1,199 @@
+package settlement
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+
+	"ball-knowledge/database"
+	"ball-knowledge/leaderboard"
+	"ball-knowledge/models"
+	"ball-knowledge/scoring"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Summary describes the outcome of a settlement or re-score
+type Summary struct {
+	Matches     int `json:"matches"`
+	Predictions int `json:"predictions"`
+	Updated     int `json:"updated"`
+}
+
+// Scope selects the matches to re-score. Empty fields match everything.
+type Scope struct {
+	League   string `json:"league"`
+	Season   string `json:"season"`
+	MatchDay int    `json:"gameweek"`
+}
+
+// SettleMatch recomputes the points of every prediction on a match using tx.
+// Predictions on cancelled matches are voided. Only predictions whose points,
+// void flag or accuracy flags changed are written, so settling twice is a no-op.
+func SettleMatch(tx *gorm.DB, matchID uuid.UUID) (Summary, error) {
+	summary := Summary{Matches: 1}
+
+	var match models.Match
+	if err := tx.Where("id = ?", matchID).First(&match).Error; err != nil {
+		return summary, fmt.Errorf("failed to load match %s: %v", matchID, err)
+	}
+
+	var predictions []models.Prediction
+	if err := tx.Where("match_id = ?", matchID).Find(&predictions).Error; err != nil {
+		return summary, fmt.Errorf("failed to load predictions for match %s: %v", matchID, err)
+	}
+
+	ruleSet, ruleSetID, err := scoring.ForCompetition(tx, match.League, match.Season)
+	if err != nil {
+		return summary, fmt.Errorf("failed to load scoring rules for match %s: %v", matchID, err)
+	}
+
+	voided := match.Status == models.MatchStatusCancelled
+
+	for _, prediction := range predictions {
+		summary.Predictions++
+
+		result := scoring.ScorePrediction(ruleSet, match, prediction.PredictedScoreHome, prediction.PredictedScoreAway)
+
+		// Only finished matches get a breakdown; anything else is unscored
+		var breakdown *models.PointsBreakdown
+		if match.Status == models.MatchStatusFinished {
+			breakdown = result.Breakdown(ruleSet)
+		}
+
+		exactScore, correctOutcome := scoring.Accuracy(match, prediction.PredictedScoreHome, prediction.PredictedScoreAway)
+
+		if result.Points == prediction.Points && voided == prediction.Voided &&
+			prediction.RuleSetID != nil && *prediction.RuleSetID == ruleSetID &&
+			reflect.DeepEqual(breakdown, prediction.Breakdown) &&
+			exactScore == prediction.ExactScore && correctOutcome == prediction.CorrectOutcome {
+			continue
+		}
+
+		if err := tx.Model(&models.Prediction{}).
+			Where("id = ?", prediction.ID).
+			Updates(map[string]interface{}{
+				"points":          result.Points,
+				"voided":          voided,
+				"rule_set_id":     ruleSetID,
+				"breakdown":       breakdown,
+				"exact_score":     exactScore,
+				"correct_outcome": correctOutcome,
+			}).Error; err != nil {
+			return summary, fmt.Errorf("failed to update prediction %s: %v", prediction.ID, err)
+		}
+
+		settled := prediction
+		settled.Points = result.Points
+		settled.Voided = voided
+		settled.ExactScore = exactScore
+		settled.CorrectOutcome = correctOutcome
+		if err := leaderboard.UpdateStanding(tx, prediction.UserID, &prediction, &settled); err != nil {
+			return summary, fmt.Errorf("failed to update standing for prediction %s: %v", prediction.ID, err)
+		}
+		summary.Updated++
+	}
+
+	// Rank snapshots taken since this match kicked off included its old points
+	if summary.Updated > 0 {
+		if err := leaderboard.InvalidateSnapshots(tx, match.Date); err != nil {
+			return summary, fmt.Errorf("failed to invalidate rank snapshots for match %s: %v", matchID, err)
+		}
+	}
+
+	return summary, nil
+}
+
+// Settle recomputes the points of every prediction on a match in its own transaction,
+// then snapshots the standings if that completed a gameweek
+func Settle(matchID uuid.UUID) (Summary, error) {
+	summary, err := settle(matchID)
+	if err != nil {
+		return summary, err
+	}
+	return summary, RecordSnapshots()
+}
+
+// RecordSnapshots snapshots the standings of gameweeks completed since the last call.
+// Call it once settlements are committed, e.g. after a fixture sync.
+func RecordSnapshots() error {
+	_, err := leaderboard.RecordSnapshots()
+	return err
+}
+
+func settle(matchID uuid.UUID) (Summary, error) {
+	var summary Summary
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		summary, err = SettleMatch(tx, matchID)
+		return err
+	})
+	return summary, err
+}
+
+// Rescore settles every match in scope, one transaction per match
+func Rescore(scope Scope) (Summary, error) {
+	var total Summary
+
+	query := database.DB.Model(&models.Match{})
+	if scope.League != "" {
+		query = query.Where("league = ?", scope.League)
+	}
+	if scope.Season != "" {
+		query = query.Where("season = ?", scope.Season)
+	}
+	if scope.MatchDay != 0 {
+		query = query.Where("match_day = ?", scope.MatchDay)
+	}
+
+	var matchIDs []uuid.UUID
+	if err := query.Pluck("id", &matchIDs).Error; err != nil {
+		return total, fmt.Errorf("failed to load matches: %v", err)
+	}
+
+	for _, matchID := range matchIDs {
+		summary, err := settle(matchID)
+		if err != nil {
+			return total, err
+		}
+		total.Matches += summary.Matches
+		total.Predictions += summary.Predictions
+		total.Updated += summary.Updated
+	}
+
+	log.Printf("✅ Re-scored %d matches: %d of %d predictions changed",
+		total.Matches, total.Updated, total.Predictions)
+	return total, RecordSnapshots()
+}
+
+// SettleUnscored settles finished matches that have predictions without a points breakdown,
+// such as predictions scored before breakdowns were stored
+func SettleUnscored() (Summary, error) {
+	var total Summary
+
+	var matchIDs []uuid.UUID
+	if err := database.DB.Model(&models.Match{}).
+		Where("status = ?", models.MatchStatusFinished).
+		Where("id IN (?)", database.DB.Model(&models.Prediction{}).
+			Select("match_id").
+			Where("breakdown IS NULL")).
+		Pluck("id", &matchIDs).Error; err != nil {
+		return total, fmt.Errorf("failed to find unscored matches: %v", err)
+	}
+
+	for _, matchID := range matchIDs {
+		summary, err := settle(matchID)
+		if err != nil {
+			return total, err
+		}
+		total.Matches += summary.Matches
+		total.Predictions += summary.Predictions
+		total.Updated += summary.Updated
+	}
+
+	if total.Matches > 0 {
+		log.Printf("✅ Settled %d matches with unscored predictions", total.Matches)
+	}
+	return total, RecordSnapshots()
+}
@@ -0,0 +1,165 @@
+package settlement
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ball-knowledge/database"
+	"ball-knowledge/database/databasetest"
+	"ball-knowledge/leaderboard"
+	"ball-knowledge/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// seedMatch stores a finished 2:1 match that alice predicted exactly and bob got wrong
+func seedMatch(t *testing.T) (match models.Match, alice, bob models.Prediction) {
+	t.Helper()
+
+	match = models.Match{
+		HomeTeam: "Arsenal",
+		AwayTeam: "Chelsea",
+		Date:     time.Date(2024, 8, 17, 14, 0, 0, 0, time.UTC),
+		League:   "Premier League",
+		Season:   "2024",
+		MatchDay: 1,
+		Result:   "2:1",
+		Status:   models.MatchStatusFinished,
+	}
+	if err := database.DB.Create(&match).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	alice = predict(t, "alice", match, 2, 1)
+	bob = predict(t, "bob", match, 0, 0)
+	return match, alice, bob
+}
+
+// predict stores a user's prediction and its standing, as placing it through the API does
+func predict(t *testing.T, username string, match models.Match, home, away int) models.Prediction {
+	t.Helper()
+
+	user := models.User{Username: username, Email: username + "@example.com", Password: "x", Role: models.RoleUser}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	prediction := models.Prediction{UserID: user.ID, MatchID: match.ID, PredictedScoreHome: home, PredictedScoreAway: away}
+	if err := database.DB.Create(&prediction).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := leaderboard.UpdateStanding(database.DB, user.ID, nil, &prediction); err != nil {
+		t.Fatal(err)
+	}
+	return prediction
+}
+
+func TestSettleMatchTwiceChangesNothing(t *testing.T) {
+	databasetest.Open(t)
+	match, alice, bob := seedMatch(t)
+
+	summary, err := Settle(match.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Predictions != 2 || summary.Updated != 2 {
+		t.Errorf("first settlement: %+v, want 2 predictions updated", summary)
+	}
+	assertPrediction(t, alice, 20, false)
+	assertPrediction(t, bob, 0, false)
+	assertStanding(t, alice.UserID, 20, 1)
+	assertStanding(t, bob.UserID, 0, 1)
+
+	summary, err = Settle(match.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Updated != 0 {
+		t.Errorf("second settlement updated %d predictions, want none", summary.Updated)
+	}
+	assertPrediction(t, alice, 20, false)
+	assertStanding(t, alice.UserID, 20, 1)
+	assertStanding(t, bob.UserID, 0, 1)
+}
+
+func TestSettleMatchRescoresCorrectedResult(t *testing.T) {
+	databasetest.Open(t)
+	match, alice, bob := seedMatch(t)
+	if _, err := Settle(match.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	database.DB.Model(&match).Update("result", "0:0")
+	summary, err := Settle(match.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Updated != 2 {
+		t.Errorf("corrected result updated %d predictions, want 2", summary.Updated)
+	}
+	assertPrediction(t, alice, 0, false)
+	assertPrediction(t, bob, 20, false)
+	assertStanding(t, alice.UserID, 0, 1)
+	assertStanding(t, bob.UserID, 20, 1)
+}
+
+func TestSettleMatchVoidsCancelledMatch(t *testing.T) {
+	databasetest.Open(t)
+	match, alice, bob := seedMatch(t)
+	if _, err := Settle(match.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	database.DB.Model(&match).Updates(map[string]interface{}{"status": models.MatchStatusCancelled, "result": ""})
+	if _, err := Settle(match.ID); err != nil {
+		t.Fatal(err)
+	}
+	assertPrediction(t, alice, 0, true)
+	assertPrediction(t, bob, 0, true)
+	assertStanding(t, alice.UserID, 0, 0)
+	assertStanding(t, bob.UserID, 0, 0)
+}
+
+func TestSettleMatchRollsBackWithItsTransaction(t *testing.T) {
+	databasetest.Open(t)
+	match, alice, _ := seedMatch(t)
+
+	abort := errors.New("abort")
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := SettleMatch(tx, match.ID); err != nil {
+			return err
+		}
+		return abort
+	})
+	if !errors.Is(err, abort) {
+		t.Fatalf("got %v, want the aborted transaction", err)
+	}
+
+	// Neither the prediction nor the standing kept the points
+	assertPrediction(t, alice, 0, false)
+	assertStanding(t, alice.UserID, 0, 1)
+}
+
+func assertPrediction(t *testing.T, prediction models.Prediction, points int, voided bool) {
+	t.Helper()
+	if err := database.DB.First(&prediction, "id = ?", prediction.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if prediction.Points != points || prediction.Voided != voided {
+		t.Errorf("%d:%d has %d points, voided %v; want %d points, voided %v",
+			prediction.PredictedScoreHome, prediction.PredictedScoreAway, prediction.Points, prediction.Voided, points, voided)
+	}
+}
+
+func assertStanding(t *testing.T, userID uuid.UUID, points, predictions int) {
+	t.Helper()
+	var standing models.Standing
+	if err := database.DB.First(&standing, "user_id = ?", userID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if standing.TotalPoints != points || standing.PredictionCount != predictions {
+		t.Errorf("standing has %d points from %d predictions, want %d from %d",
+			standing.TotalPoints, standing.PredictionCount, points, predictions)
+	}
+}
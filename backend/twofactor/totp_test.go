@@ -0,0 +1,90 @@
+package twofactor
+
+import (
+	"testing"
+	"time"
+)
+
+// The SHA-1 secret of the RFC 6238 test vectors, "12345678901234567890", base32 encoded
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func rfcKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := secretEncoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestValidateRFC6238Vectors(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, truncated to our six digits
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, v := range vectors {
+		now := time.Unix(v.unix, 0)
+		step, ok := Validate(rfcSecret, v.code, 0, now)
+		if !ok || step != v.unix/period {
+			t.Errorf("%s at %d: got step %d, ok %v; want step %d", v.code, v.unix, step, ok, v.unix/period)
+		}
+	}
+
+	// Lower case secrets and spaced codes, as typed from an app, are accepted too
+	if _, ok := Validate("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "287 082", 0, time.Unix(59, 0)); !ok {
+		t.Error("lower case secret or spaced code rejected")
+	}
+}
+
+func TestValidateAllowsClockSkew(t *testing.T) {
+	key := rfcKey(t)
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / period
+
+	for offset := int64(-2); offset <= 2; offset++ {
+		step, ok := Validate(rfcSecret, generate(key, current+offset), 0, now)
+		if want := offset >= -skew && offset <= skew; ok != want {
+			t.Errorf("code %d steps from now: accepted %v, want %v", offset, ok, want)
+		} else if ok && step != current+offset {
+			t.Errorf("code %d steps from now matched step %d", offset, step)
+		}
+	}
+}
+
+func TestValidateRejectsUsedSteps(t *testing.T) {
+	key := rfcKey(t)
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / period
+	code := generate(key, current)
+
+	if _, ok := Validate(rfcSecret, code, current, now); ok {
+		t.Error("code accepted again after its step was used")
+	}
+	if _, ok := Validate(rfcSecret, generate(key, current-1), current, now); ok {
+		t.Error("code of an earlier step accepted after a later one was used")
+	}
+	if _, ok := Validate(rfcSecret, code, current-1, now); !ok {
+		t.Error("code rejected when only an earlier step was used")
+	}
+}
+
+func TestValidateRejectsMalformedInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870820", "abcdef"} {
+		if _, ok := Validate(rfcSecret, code, 0, now); ok {
+			t.Errorf("code %q accepted", code)
+		}
+	}
+	if _, ok := Validate("not base32!", "287082", 0, now); ok {
+		t.Error("invalid secret accepted")
+	}
+}
@@ -0,0 +1,177 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ball-knowledge/config"
+	"ball-knowledge/database"
+	"ball-knowledge/models"
+	"ball-knowledge/sessions"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount is how many recovery codes a user gets at a time
+const recoveryCodeCount = 10
+
+var (
+	// ErrAlreadyEnabled is returned when enrolling a user who already has two-factor authentication
+	ErrAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrNotEnrolled is returned when the user hasn't started enrolment or doesn't have two-factor authentication
+	ErrNotEnrolled = errors.New("two-factor authentication is not set up")
+	// ErrInvalidCode is returned for wrong, expired or already used codes
+	ErrInvalidCode = errors.New("invalid two-factor code")
+)
+
+// Issuer names the app in authenticator apps
+func Issuer() string {
+	return config.String("TOTP_ISSUER", "Ball Knowledge")
+}
+
+// ChallengeTTL is how long a user has to enter their code after their password
+func ChallengeTTL() time.Duration {
+	return config.Duration("TWO_FACTOR_CHALLENGE_TTL", 5*time.Minute)
+}
+
+// BeginEnrollment generates a new TOTP secret for a user and returns it with its otpauth
+// URI. Two-factor authentication is enabled once ConfirmEnrollment sees a code from it.
+func BeginEnrollment(user models.User) (secret, uri string, err error) {
+	if user.TOTPEnabled {
+		return "", "", ErrAlreadyEnabled
+	}
+
+	secret, err = NewSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := database.DB.Model(&models.User{}).Where("id = ?", user.ID).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		return "", "", err
+	}
+	return secret, URI(Issuer(), user.Username, secret), nil
+}
+
+// ConfirmEnrollment enables two-factor authentication once the user proves their app
+// produces codes for the new secret, and returns their recovery codes
+func ConfirmEnrollment(user models.User, code string) ([]string, error) {
+	if user.TOTPEnabled {
+		return nil, ErrAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrNotEnrolled
+	}
+
+	step, ok := Validate(user.TOTPSecret, code, user.TOTPLastStep, time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).
+			Updates(map[string]interface{}{"totp_enabled": true, "totp_last_step": step}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = RegenerateRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// Verify checks a TOTP code, or failing that a recovery code, for a user with two-factor
+// authentication. Each code works once. It reports whether a recovery code was used.
+func Verify(user models.User, code string) (bool, error) {
+	if !user.TOTPEnabled {
+		return false, ErrNotEnrolled
+	}
+
+	if step, ok := Validate(user.TOTPSecret, code, user.TOTPLastStep, time.Now()); ok {
+		// Claim the step, so a concurrent login can't reuse the same code
+		result := database.DB.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return false, ErrInvalidCode
+		}
+		return false, nil
+	}
+
+	result := database.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, sessions.HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, ErrInvalidCode
+	}
+	return true, nil
+}
+
+// Disable turns off two-factor authentication for a user and deletes their recovery codes
+func Disable(tx *gorm.DB, userID uuid.UUID) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"totp_enabled": false, "totp_secret": "", "totp_last_step": 0}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes and returns the new ones.
+// Only their hashes are stored, so this is the only time they can be shown.
+func RegenerateRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: sessions.HashToken(normalizeRecoveryCode(code)),
+		}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// RemainingRecoveryCodes counts a user's unused recovery codes
+func RemainingRecoveryCodes(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := database.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// newRecoveryCode generates an 80-bit code formatted for reading, e.g. "k3jd-9fwq-2mzx-p7rt"
+func newRecoveryCode() (string, error) {
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %v", err)
+	}
+	encoded := strings.ToLower(secretEncoding.EncodeToString(raw))
+	return encoded[0:4] + "-" + encoded[4:8] + "-" + encoded[8:12] + "-" + encoded[12:16], nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes in a typed recovery code
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
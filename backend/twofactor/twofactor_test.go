@@ -0,0 +1,119 @@
+package twofactor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"ball-knowledge/database"
+	"ball-knowledge/database/databasetest"
+	"ball-knowledge/models"
+)
+
+// enrolledUser creates a user with two-factor authentication enabled, and returns them
+// with their recovery codes and the code that confirmed enrolment
+func enrolledUser(t *testing.T) (models.User, []string, string) {
+	t.Helper()
+
+	user := models.User{Username: "admin", Email: "admin@example.com", Password: "x", Role: models.RoleAdmin}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	secret, _, err := BeginEnrollment(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.TOTPSecret = secret
+
+	code := currentCode(t, secret, 0)
+	codes, err := ConfirmEnrollment(user, code)
+	if err != nil {
+		t.Fatalf("enrolment failed: %v", err)
+	}
+	return reload(t, user), codes, code
+}
+
+// currentCode is the code an authenticator app shows offset steps from now
+func currentCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	key, err := secretEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return generate(key, time.Now().Unix()/period+offset)
+}
+
+func reload(t *testing.T, user models.User) models.User {
+	t.Helper()
+	if err := database.DB.First(&user, "id = ?", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestVerifyAcceptsEachCodeOnce(t *testing.T) {
+	databasetest.Open(t)
+	user, _, enrolmentCode := enrolledUser(t)
+
+	// The enrolment code's step is used up
+	if _, err := Verify(user, enrolmentCode); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("enrolment code reused: got %v, want ErrInvalidCode", err)
+	}
+
+	next := currentCode(t, user.TOTPSecret, 1)
+	if usedRecoveryCode, err := Verify(user, next); err != nil || usedRecoveryCode {
+		t.Fatalf("next code: got recovery %v, err %v", usedRecoveryCode, err)
+	}
+
+	// A stale copy of the user, as a concurrent login would have, can't reuse it either
+	if _, err := Verify(user, next); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("code reused with a stale user: got %v, want ErrInvalidCode", err)
+	}
+	if _, err := Verify(reload(t, user), next); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("code reused: got %v, want ErrInvalidCode", err)
+	}
+}
+
+func TestVerifyAcceptsRecoveryCodeOnce(t *testing.T) {
+	databasetest.Open(t)
+	user, codes, _ := enrolledUser(t)
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	// Typed in upper case without dashes
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	if usedRecoveryCode, err := Verify(user, typed); err != nil || !usedRecoveryCode {
+		t.Fatalf("recovery code: got recovery %v, err %v", usedRecoveryCode, err)
+	}
+	if _, err := Verify(user, codes[0]); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("recovery code reused: got %v, want ErrInvalidCode", err)
+	}
+
+	if remaining, err := RemainingRecoveryCodes(user.ID); err != nil || remaining != recoveryCodeCount-1 {
+		t.Errorf("%d recovery codes remaining, want %d", remaining, recoveryCodeCount-1)
+	}
+}
+
+func TestConfirmEnrollmentRejectsWrongCode(t *testing.T) {
+	databasetest.Open(t)
+
+	user := models.User{Username: "admin", Email: "admin@example.com", Password: "x", Role: models.RoleAdmin}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	secret, _, err := BeginEnrollment(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.TOTPSecret = secret
+
+	if _, err := ConfirmEnrollment(user, currentCode(t, secret, 3)); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("got %v, want ErrInvalidCode", err)
+	}
+	if user = reload(t, user); user.TOTPEnabled {
+		t.Error("two-factor authentication enabled by a wrong code")
+	}
+}